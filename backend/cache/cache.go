@@ -0,0 +1,81 @@
+// Package cache abstracts the key-value store behind the user caches, so
+// handlers do not depend on the Redis client and can run without a cache.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss is returned for keys that are not cached.
+var ErrMiss = errors.New("cache miss")
+
+// ErrDisabled is returned by NoCache for operations that need a real store,
+// such as Ping and Lock.
+var ErrDisabled = errors.New("cache disabled")
+
+// Cache is the subset of Redis the handlers use.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	// MGet returns one value per key, nil for misses.
+	MGet(ctx context.Context, keys ...string) ([][]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetMany stores entries in a single round trip.
+	SetMany(ctx context.Context, entries map[string][]byte, ttl time.Duration) error
+	// SetIndexed stores key and adds it to the index set atomically; the
+	// index expires along with it.
+	SetIndexed(ctx context.Context, index, key string, value []byte, ttl time.Duration) error
+	// Members lists the keys recorded in an index set.
+	Members(ctx context.Context, index string) ([]string, error)
+	// TTL returns the remaining lifetime of key.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Del(ctx context.Context, keys ...string) error
+	Publish(ctx context.Context, channel, message string) error
+	// Subscribe calls handle for each message on channel until ctx is done.
+	Subscribe(ctx context.Context, channel string, handle func(message string)) error
+	// Lock takes key for ttl and returns the func releasing it, or
+	// lock.ErrNotAcquired when another holder owns it.
+	Lock(ctx context.Context, key string, ttl time.Duration) (func(context.Context) error, error)
+	Ping(ctx context.Context) error
+}
+
+// Enabled reports whether c is backed by a real store.
+func Enabled(c Cache) bool {
+	_, disabled := c.(NoCache)
+	return !disabled
+}
+
+// NoCache is a Cache that stores nothing: every read misses and every write
+// is dropped, so handlers serve straight from the database.
+type NoCache struct{}
+
+func (NoCache) Get(context.Context, string) ([]byte, error) { return nil, ErrMiss }
+
+func (NoCache) MGet(_ context.Context, keys ...string) ([][]byte, error) {
+	return make([][]byte, len(keys)), nil
+}
+
+func (NoCache) Set(context.Context, string, []byte, time.Duration) error { return nil }
+
+func (NoCache) SetMany(context.Context, map[string][]byte, time.Duration) error { return nil }
+
+func (NoCache) SetIndexed(context.Context, string, string, []byte, time.Duration) error {
+	return nil
+}
+
+func (NoCache) Members(context.Context, string) ([]string, error) { return nil, nil }
+
+func (NoCache) TTL(context.Context, string) (time.Duration, error) { return 0, ErrMiss }
+
+func (NoCache) Del(context.Context, ...string) error { return nil }
+
+func (NoCache) Publish(context.Context, string, string) error { return nil }
+
+func (NoCache) Subscribe(context.Context, string, func(string)) error { return nil }
+
+func (NoCache) Lock(context.Context, string, time.Duration) (func(context.Context) error, error) {
+	return nil, ErrDisabled
+}
+
+func (NoCache) Ping(context.Context) error { return ErrDisabled }
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s-autoscale-webapp/lock"
+	"k8s-autoscale-webapp/metrics"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// deleteBatchSize bounds the keys per UNLINK/DEL command in a pipeline.
+const deleteBatchSize = 500
+
+// Redis is a Cache backed by a Redis server.
+type Redis struct {
+	client *redis.Client
+	// unlinkUnsupported is set once Redis rejects UNLINK (pre-4.0 servers).
+	unlinkUnsupported atomic.Bool
+}
+
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	switch {
+	case errors.Is(err, redis.Nil):
+		metrics.CacheMisses.Inc()
+		return nil, ErrMiss
+	case err != nil:
+		metrics.CacheErrors.Inc()
+		return nil, err
+	}
+	metrics.CacheHits.Inc()
+	return value, nil
+}
+
+func (r *Redis) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		metrics.CacheErrors.Add(float64(len(keys)))
+		return nil, err
+	}
+	result := make([][]byte, len(values))
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			result[i] = []byte(s)
+			metrics.CacheHits.Inc()
+		} else {
+			metrics.CacheMisses.Inc()
+		}
+	}
+	return result, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *Redis) SetMany(ctx context.Context, entries map[string][]byte, ttl time.Duration) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	pipe := r.client.Pipeline()
+	for key, value := range entries {
+		pipe.Set(ctx, key, value, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *Redis) SetIndexed(ctx context.Context, index, key string, value []byte, ttl time.Duration) error {
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, key, value, ttl)
+	pipe.SAdd(ctx, index, key)
+	pipe.Expire(ctx, index, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *Redis) Members(ctx context.Context, index string) ([]string, error) {
+	return r.client.SMembers(ctx, index).Result()
+}
+
+func (r *Redis) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return r.client.PTTL(ctx, key).Result()
+}
+
+// Del removes keys in pipelined batches using UNLINK, which frees memory in
+// the background instead of blocking Redis like DEL. It falls back to DEL
+// when the server does not support UNLINK.
+func (r *Redis) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	unlink := !r.unlinkUnsupported.Load()
+	err := r.pipelineDelete(ctx, keys, unlink)
+	if unlink && err != nil && strings.Contains(strings.ToLower(err.Error()), "unknown command") {
+		r.unlinkUnsupported.Store(true)
+		err = r.pipelineDelete(ctx, keys, false)
+	}
+	return err
+}
+
+func (r *Redis) pipelineDelete(ctx context.Context, keys []string, unlink bool) error {
+	pipe := r.client.Pipeline()
+	for start := 0; start < len(keys); start += deleteBatchSize {
+		batch := keys[start:min(start+deleteBatchSize, len(keys))]
+		if unlink {
+			pipe.Unlink(ctx, batch...)
+		} else {
+			pipe.Del(ctx, batch...)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *Redis) Publish(ctx context.Context, channel, message string) error {
+	return r.client.Publish(ctx, channel, message).Err()
+}
+
+func (r *Redis) Subscribe(ctx context.Context, channel string, handle func(message string)) error {
+	sub := r.client.Subscribe(ctx, channel)
+	defer sub.Close()
+
+	messages := sub.Channel()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			handle(msg.Payload)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *Redis) Lock(ctx context.Context, key string, ttl time.Duration) (func(context.Context) error, error) {
+	l, err := lock.Acquire(ctx, r.client, key, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return l.Release, nil
+}
+
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
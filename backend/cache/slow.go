@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"k8s-autoscale-webapp/metrics"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type startKey struct{}
+
+// slowHook logs and counts Redis commands, and pipelines, that take at least
+// threshold.
+type slowHook struct {
+	threshold time.Duration
+}
+
+// LogSlow logs every command taking at least threshold with its operation
+// and key, and counts it in cache_slow_operations_total. A zero threshold
+// leaves commands untimed.
+func (r *Redis) LogSlow(threshold time.Duration) {
+	if threshold > 0 {
+		r.client.AddHook(slowHook{threshold: threshold})
+	}
+}
+
+func (h slowHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, startKey{}, time.Now()), nil
+}
+
+func (h slowHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.observe(ctx, cmd.Name(), cmdKey(cmd), 1)
+	return nil
+}
+
+func (h slowHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, startKey{}, time.Now()), nil
+}
+
+func (h slowHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var key string
+	if len(cmds) > 0 {
+		key = cmdKey(cmds[0])
+	}
+	h.observe(ctx, "pipeline", key, len(cmds))
+	return nil
+}
+
+func (h slowHook) observe(ctx context.Context, op, key string, commands int) {
+	start, ok := ctx.Value(startKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start)
+	if elapsed < h.threshold {
+		return
+	}
+	metrics.CacheSlowOperations.WithLabelValues(op).Inc()
+	slog.Warn("Slow cache operation", "operation", op, "key", key, "commands", commands, "duration", elapsed)
+}
+
+// cmdKey returns the first key cmd operates on, or "" for keyless commands.
+func cmdKey(cmd redis.Cmder) string {
+	if args := cmd.Args(); len(args) > 1 {
+		return fmt.Sprint(args[1])
+	}
+	return ""
+}
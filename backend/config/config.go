@@ -3,12 +3,32 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
+	// Environment names the deployment (e.g. dev, staging, prod) in health
+	// responses, metrics and logs.
+	Environment    string
 	DatabaseConfig DatabaseConfig
-	RedisConfig    RedisConfig
-	ServerConfig   ServerConfig
+	// ReadDatabaseConfig points at a read replica; its Host is empty when
+	// no replica is configured.
+	ReadDatabaseConfig DatabaseConfig
+	RedisConfig        RedisConfig
+	ServerConfig       ServerConfig
+	APIConfig          APIConfig
+	RateLimitConfig    RateLimitConfig
+	BreakerConfig      BreakerConfig
+	WebhookConfig      WebhookConfig
+	ConcurrencyConfig  ConcurrencyConfig
+	StressConfig       StressConfig
+	ChaosConfig        ChaosConfig
+	PodConfig          PodConfig
+	AccessLogConfig    AccessLogConfig
+	ReplayConfig       ReplayConfig
+	CaptureConfig      CaptureConfig
 }
 
 type DatabaseConfig struct {
@@ -17,52 +37,571 @@ type DatabaseConfig struct {
 	User     string
 	Password string
 	DBName   string
+	// SSLMode is the libpq sslmode; "disable" unless DATABASE_URL sets one.
+	SSLMode string
+	// MaxOpenConns of 0 means unlimited, matching database/sql.
+	MaxOpenConns int
+	MaxIdleConns int
+	// ConnMaxLifetime recycles pooled connections, bounding how long one
+	// can outlive a failover that went undetected; 0 keeps them forever.
+	ConnMaxLifetime time.Duration
+	// SchemaCheckStrict refuses to start against a schema version newer
+	// than the binary supports instead of only warning.
+	SchemaCheckStrict bool
+	// StatementTimeout makes Postgres abort queries running longer than
+	// this, even after the client has given up; 0 disables it.
+	StatementTimeout time.Duration
+	// WarmupConns connections are opened and pinged at startup, within
+	// WarmupTimeout, so the first requests don't pay for connecting. It is
+	// capped at MaxIdleConns; 0 disables warmup.
+	WarmupConns   int
+	WarmupTimeout time.Duration
+	// ApplicationName identifies this pod's connections, e.g. so it can
+	// skip change notifications for its own writes.
+	ApplicationName string
+	// PreparedStatements reuses prepared statements for the hot queries.
+	// Turning it off sends them as plain queries, to compare latencies
+	// under load or to run behind a transaction-pooling proxy.
+	PreparedStatements bool
 }
 
 type RedisConfig struct {
+	// Host unset runs the backend without a cache, serving every read
+	// from the database, unless Required makes that a startup error.
 	Host     string
 	Port     string
+	Username string
 	Password string
 	DB       int
+	// TLS is set by rediss:// URLs.
+	TLS      bool
+	Required bool
+	// SlowThreshold logs and counts commands taking at least this long;
+	// 0 disables it.
+	SlowThreshold time.Duration
 }
 
 type ServerConfig struct {
 	Port string
+	// ShutdownDrainDelay is how long the server keeps serving after SIGTERM
+	// while reporting not-ready, giving Kubernetes time to remove the pod
+	// from the service endpoints.
+	ShutdownDrainDelay time.Duration
+	ShutdownTimeout    time.Duration
+	// InterruptShutdownTimeout replaces the drain delay and ShutdownTimeout
+	// on SIGINT, so local Ctrl-C exits quickly.
+	InterruptShutdownTimeout time.Duration
+	// ShutdownHookTimeout bounds each cleanup step (closing the DB, Redis,
+	// draining webhooks, ...) run after the server stops.
+	ShutdownHookTimeout time.Duration
+	// MaintenanceMode rejects writes with 503 while reads keep serving.
+	MaintenanceMode       bool
+	MaintenanceRetryAfter time.Duration
+	// JWTSecret verifies HS256 bearer tokens for authenticated endpoints;
+	// empty rejects all of them.
+	JWTSecret string
+	// AdminToken is the bearer token for /api/admin endpoints; empty
+	// disables them.
+	AdminToken string
+	// HealthCacheTTL is how long health dependency checks are reused.
+	HealthCacheTTL time.Duration
+	// MaxRequestTimeout caps deadlines requested via X-Request-Timeout.
+	MaxRequestTimeout time.Duration
+	// TrailingSlashMode is "rewrite" (default) or "redirect".
+	TrailingSlashMode string
+	// Connection tuning; see "Connection Keepalive" in the README for how
+	// these interact with kube-proxy and the ingress controller.
+	ReadHeaderTimeout time.Duration
+	IdleTimeout       time.Duration
+	HTTPKeepAlives    bool
+	// TCPKeepAlive is the TCP keepalive probe period on accepted
+	// connections; negative disables it.
+	TCPKeepAlive time.Duration
+	// MaxURLLength and MaxQueryParamLength bound request URIs and individual
+	// query values to keep oversized query strings from being parsed.
+	MaxURLLength        int
+	MaxQueryParamLength int
+	// MaxRequestBodyBytes caps request bodies; larger ones get 413. 0
+	// disables the cap.
+	MaxRequestBodyBytes int64
+	// UserAgentFilter rejects requests with an empty or blocklisted
+	// User-Agent; agents on the allowlist always pass.
+	UserAgentFilter    bool
+	UserAgentAllowlist []string
+	UserAgentBlocklist []string
+	// JSONErrors turns plain-text error responses into JSON bodies that
+	// carry the request ID.
+	JSONErrors bool
+	// HealthDegradedLatency makes /health report "degraded" when the DB or
+	// Redis answers its ping slower than this; 0 disables it.
+	HealthDegradedLatency time.Duration
+	// DisabledEndpoints names endpoints (e.g. "users.upsert", "stress")
+	// that answer 404 instead of being served.
+	DisabledEndpoints []string
+	// RequiredDeps lists the dependencies ("db", "redis") that must be
+	// connected for /readyz to pass; others are reported but optional.
+	// Empty by default, so readiness does not depend on either.
+	RequiredDeps []string
+	// ReadinessPoolCheck fails readiness while the DB pool is saturated:
+	// every connection in use and at least ReadinessPoolWaitThreshold new
+	// waits since the previous probe.
+	ReadinessPoolCheck         bool
+	ReadinessPoolWaitThreshold int
+	// SettingsRefreshInterval is how often runtime overrides are reloaded
+	// from the settings table.
+	SettingsRefreshInterval time.Duration
+	// TrustedProxies lists the CIDRs (or IPs) whose X-Forwarded-For is
+	// believed when resolving the client IP.
+	TrustedProxies []string
+	// CgroupGOMAXPROCS sets GOMAXPROCS from the container CPU limit at
+	// startup unless the GOMAXPROCS environment variable is set.
+	CgroupGOMAXPROCS bool
+	// ServerTiming adds a Server-Timing header with the db, cache and total
+	// time of each request. Off by default, since it tells any client how
+	// long the backend spends on the database and cache.
+	ServerTiming bool
+	// ShedTargetLatency enables adaptive load shedding: while the p99
+	// latency exceeds it, up to ShedMaxRate of requests get 503. 0 disables
+	// shedding.
+	ShedTargetLatency time.Duration
+	ShedMaxRate       float64
+	// AdminAllowedCIDRs restricts admin endpoints to these client CIDRs (or
+	// IPs), on top of the admin token; empty allows any client.
+	AdminAllowedCIDRs []string
 }
 
-func Load() *Config {
+type APIConfig struct {
+	// ResponseEnvelope wraps user responses as {"data":...,"meta":...}.
+	ResponseEnvelope bool
+	// JSONNaming selects response key casing: "snake" (default) or "camel".
+	JSONNaming string
+	CacheTTL   time.Duration
+	// CacheWarm preloads the user list at startup.
+	CacheWarm bool
+	// CacheSoftTTL, when positive and below CacheTTL, is the age after which
+	// a cached user or user list is still served but refreshed in the
+	// background (stale-while-revalidate).
+	CacheSoftTTL time.Duration
+	// CacheHeaders adds X-Cache and X-Cache-TTL to cached user responses.
+	CacheHeaders bool
+	// L1CacheSize enables a per-pod LRU of single users in front of Redis
+	// when positive. Entries live at most L1CacheTTL to bound staleness.
+	L1CacheSize int
+	L1CacheTTL  time.Duration
+	// CacheBypass honors Cache-Control: no-cache and ?nocache=true on user
+	// reads, forcing a DB read that refreshes the cache. Off by default so
+	// clients cannot defeat the cache under normal load.
+	CacheBypass bool
+	// CacheListenNotify invalidates cached users on Postgres NOTIFYs from
+	// the users table trigger, catching changes made outside the app.
+	CacheListenNotify bool
+	// UpdateNullClears makes an explicit null name in a user update clear
+	// it instead of being rejected.
+	UpdateNullClears bool
+	// DefaultUserName lets POST /api/users omit name, deriving one from the
+	// email local-part. When false a name is required.
+	DefaultUserName bool
+	// MaxUsers is a soft cap on the number of users: creates get 429 once
+	// it is reached. 0 means unlimited.
+	MaxUsers int
+}
+
+type RateLimitConfig struct {
+	// RequestsPerSecond is the per-client-IP refill rate; 0 disables limiting.
+	RequestsPerSecond float64
+	Burst             int
+}
+
+type BreakerConfig struct {
+	// MaxFailures is the number of consecutive DB failures that opens the
+	// breaker; 0 disables it.
+	MaxFailures         uint32
+	OpenTimeout         time.Duration
+	HalfOpenMaxRequests uint32
+}
+
+type WebhookConfig struct {
+	// URL receives a POST for each created user; empty disables webhooks.
+	URL         string
+	Secret      string
+	QueueSize   int
+	MaxAttempts int
+	Timeout     time.Duration
+	// Workers deliver queued events concurrently; RateLimit caps posts per
+	// second across them, retries included, with 0 meaning unlimited.
+	Workers   int
+	RateLimit float64
+}
+
+type ConcurrencyConfig struct {
+	// DBRequests caps concurrent DB-bound requests; it defaults to the
+	// connection pool size. StressRequests caps concurrent stress runs.
+	// 0 means unlimited.
+	DBRequests     int
+	StressRequests int
+}
+
+type StressConfig struct {
+	// MemoryFraction is the share of the cgroup memory limit the memory
+	// stress mode may allocate; MemoryMaxMB applies when no limit is set.
+	MemoryFraction float64
+	MemoryMaxMB    int
+	// AsyncWorkers run queued async stress jobs; submissions beyond
+	// AsyncQueueSize waiting jobs are rejected with 429.
+	AsyncWorkers   int
+	AsyncQueueSize int
+	// ProgressInterval spaces the progress events of streamed stress runs.
+	ProgressInterval time.Duration
+	// DBQueryTimeout bounds each query of the DB stress dimension,
+	// separately from request deadlines; 0 disables it.
+	DBQueryTimeout time.Duration
+}
+
+// PodConfig identifies the pod serving requests, from the Kubernetes
+// downward API. Name falls back to the hostname, which Kubernetes sets to
+// the pod name.
+type PodConfig struct {
+	Name string
+	Node string
+	IP   string
+}
+
+type AccessLogConfig struct {
+	// Enabled logs one line per request.
+	Enabled bool
+	// Up to SampleThreshold requests per second are all logged; above it
+	// only a SampleRate fraction is. Errors and requests slower than
+	// SlowThreshold are always logged.
+	SampleThreshold int
+	SampleRate      float64
+	SlowThreshold   time.Duration
+}
+
+type CaptureConfig struct {
+	// Enabled keeps the bodies of failing requests for /debug/requests.
+	// Off by default since payloads may hold personal data.
+	Enabled bool
+	// SampleRate is the fraction of requests captured; the last Size
+	// failing ones are kept, each body cut at MaxBodyBytes.
+	SampleRate   float64
+	Size         int
+	MaxBodyBytes int
+}
+
+type ReplayConfig struct {
+	// Enabled installs the admin replay endpoint, meant for test
+	// environments. MaxRequests and MaxConcurrency bound one replay.
+	Enabled        bool
+	MaxRequests    int
+	MaxConcurrency int
+}
+
+type ChaosConfig struct {
+	// Enabled installs the fault-injection middleware and its admin
+	// endpoints; nothing is injected until a fault is configured.
+	Enabled bool
+	// DelayMin and DelayMax bound the random delay added to each request;
+	// a zero DelayMax injects none.
+	DelayMin time.Duration
+	DelayMax time.Duration
+	// ErrorRate is the fraction of requests under ErrorRoutes (path
+	// prefixes) answered with 500.
+	ErrorRate   float64
+	ErrorRoutes []string
+}
+
+// Load reads the configuration from the environment. DATABASE_URL and
+// REDIS_URL, when set, take precedence over the individual DB_* and REDIS_*
+// connection variables; a malformed one is an error.
+func Load() (*Config, error) {
+	maxRequestTimeout := getEnvDuration("MAX_REQUEST_TIMEOUT", 30*time.Second)
+
+	db := DatabaseConfig{
+		Host:     getEnv("DB_HOST", "localhost"),
+		Port:     getEnv("DB_PORT", "5432"),
+		User:     getEnv("DB_USER", "postgres"),
+		Password: getEnv("DB_PASSWORD", ""),
+		DBName:   getEnv("DB_NAME", "webapp"),
+		SSLMode:  "disable",
+
+		MaxOpenConns: getEnvInt("DB_MAX_OPEN_CONNS", 0),
+		MaxIdleConns: getEnvInt("DB_MAX_IDLE_CONNS", 2),
+
+		ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+
+		SchemaCheckStrict: getEnvBool("SCHEMA_CHECK_STRICT", false),
+
+		// Defaults to the longest request deadline so the server stops
+		// work no client can still be waiting for.
+		StatementTimeout: getEnvDuration("DB_STATEMENT_TIMEOUT", maxRequestTimeout),
+	}
+	db.WarmupConns = getEnvInt("DB_WARMUP_CONNS", db.MaxIdleConns)
+	db.WarmupTimeout = getEnvDuration("DB_WARMUP_TIMEOUT", 5*time.Second)
+	db.PreparedStatements = getEnvBool("DB_PREPARED_STATEMENTS", true)
+	db.ApplicationName = getEnv("POD_NAME", hostname())
+	if raw := os.Getenv("DATABASE_URL"); raw != "" {
+		if err := applyDatabaseURL(&db, raw); err != nil {
+			return nil, err
+		}
+	}
+
+	requiredDeps, err := getEnvDeps("REQUIRED_DEPS")
+	if err != nil {
+		return nil, err
+	}
+
+	rc := RedisConfig{
+		Host:     getEnv("REDIS_HOST", ""),
+		Port:     getEnv("REDIS_PORT", "6379"),
+		Password: getEnv("REDIS_PASSWORD", ""),
+		DB:       0,
+		Required: getEnvBool("REDIS_REQUIRED", false),
+
+		SlowThreshold: getEnvDuration("REDIS_SLOW_THRESHOLD", 50*time.Millisecond),
+	}
+	if raw := os.Getenv("REDIS_URL"); raw != "" {
+		if err := applyRedisURL(&rc, raw); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Config{
-		DatabaseConfig: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", ""),
-			DBName:   getEnv("DB_NAME", "webapp"),
-		},
-		RedisConfig: RedisConfig{
-			Host:     getEnv("REDIS_HOST", "localhost"),
-			Port:     getEnv("REDIS_PORT", "6379"),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       0,
+		Environment:    getEnv("ENVIRONMENT", "development"),
+		DatabaseConfig: db,
+		ReadDatabaseConfig: DatabaseConfig{
+			Host:     getEnv("DB_READ_HOST", ""),
+			Port:     getEnv("DB_READ_PORT", db.Port),
+			User:     getEnv("DB_READ_USER", db.User),
+			Password: getEnv("DB_READ_PASSWORD", db.Password),
+			DBName:   getEnv("DB_READ_NAME", db.DBName),
+			SSLMode:  db.SSLMode,
+
+			ApplicationName: db.ApplicationName,
+
+			MaxOpenConns: db.MaxOpenConns,
+			MaxIdleConns: db.MaxIdleConns,
+
+			ConnMaxLifetime: db.ConnMaxLifetime,
+
+			StatementTimeout: db.StatementTimeout,
+
+			WarmupConns:   db.WarmupConns,
+			WarmupTimeout: db.WarmupTimeout,
 		},
+		RedisConfig: rc,
 		ServerConfig: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
+			Port:                       getEnv("SERVER_PORT", "8080"),
+			ShutdownDrainDelay:         getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+			ShutdownTimeout:            getEnvDuration("SHUTDOWN_TIMEOUT", 20*time.Second),
+			InterruptShutdownTimeout:   getEnvDuration("SHUTDOWN_INTERRUPT_TIMEOUT", 2*time.Second),
+			ShutdownHookTimeout:        getEnvDuration("SHUTDOWN_HOOK_TIMEOUT", 5*time.Second),
+			MaintenanceMode:            getEnvBool("MAINTENANCE_MODE", false),
+			MaintenanceRetryAfter:      getEnvDuration("MAINTENANCE_RETRY_AFTER", 2*time.Minute),
+			AdminToken:                 getEnv("ADMIN_TOKEN", ""),
+			JWTSecret:                  getEnv("JWT_SECRET", ""),
+			HealthCacheTTL:             getEnvDuration("HEALTH_CACHE_TTL", time.Second),
+			MaxRequestTimeout:          maxRequestTimeout,
+			TrailingSlashMode:          getEnv("TRAILING_SLASH_MODE", "rewrite"),
+			ReadHeaderTimeout:          getEnvDuration("READ_HEADER_TIMEOUT", 10*time.Second),
+			IdleTimeout:                getEnvDuration("IDLE_TIMEOUT", 75*time.Second),
+			HTTPKeepAlives:             getEnvBool("HTTP_KEEPALIVES", true),
+			TCPKeepAlive:               getEnvDuration("TCP_KEEPALIVE", 30*time.Second),
+			MaxURLLength:               getEnvInt("MAX_URL_LENGTH", 2048),
+			MaxQueryParamLength:        getEnvInt("MAX_QUERY_PARAM_LENGTH", 1024),
+			MaxRequestBodyBytes:        int64(getEnvInt("MAX_REQUEST_BODY_BYTES", 1<<20)),
+			UserAgentFilter:            getEnvBool("USER_AGENT_FILTER", false),
+			UserAgentAllowlist:         getEnvList("USER_AGENT_ALLOWLIST", []string{"k6", "hey", "wrk", "locust", "vegeta", "ApacheBench", "kube-probe", "Prometheus"}),
+			TrustedProxies:             getEnvList("TRUSTED_PROXIES", nil),
+			AdminAllowedCIDRs:          getEnvList("ADMIN_ALLOWED_CIDRS", nil),
+			ShedTargetLatency:          getEnvDuration("SHED_TARGET_LATENCY", 0),
+			ShedMaxRate:                getEnvFloat("SHED_MAX_RATE", 0.9),
+			CgroupGOMAXPROCS:           getEnvBool("CGROUP_GOMAXPROCS", true),
+			ServerTiming:               getEnvBool("SERVER_TIMING", false),
+			RequiredDeps:               requiredDeps,
+			DisabledEndpoints:          getEnvList("DISABLED_ENDPOINTS", nil),
+			HealthDegradedLatency:      getEnvDuration("HEALTH_DEGRADED_LATENCY", 250*time.Millisecond),
+			JSONErrors:                 getEnvBool("JSON_ERRORS", true),
+			ReadinessPoolCheck:         getEnvBool("READINESS_POOL_CHECK", false),
+			ReadinessPoolWaitThreshold: getEnvInt("READINESS_POOL_WAIT_THRESHOLD", 1),
+			SettingsRefreshInterval:    getEnvDuration("SETTINGS_REFRESH_INTERVAL", 30*time.Second),
+			UserAgentBlocklist:         getEnvList("USER_AGENT_BLOCKLIST", []string{"sqlmap", "nikto", "nmap", "masscan", "zgrab", "nuclei"}),
 		},
-	}
+		APIConfig: APIConfig{
+			ResponseEnvelope:  getEnvBool("RESPONSE_ENVELOPE", false),
+			JSONNaming:        getEnv("JSON_NAMING", "snake"),
+			CacheTTL:          getEnvDuration("CACHE_TTL", 5*time.Minute),
+			CacheWarm:         getEnvBool("CACHE_WARM", true),
+			CacheSoftTTL:      getEnvDuration("CACHE_SOFT_TTL", 0),
+			CacheHeaders:      getEnvBool("CACHE_DEBUG_HEADERS", false),
+			L1CacheSize:       getEnvInt("L1_CACHE_SIZE", 0),
+			L1CacheTTL:        getEnvDuration("L1_CACHE_TTL", 2*time.Second),
+			DefaultUserName:   getEnvBool("DEFAULT_USER_NAME", false),
+			MaxUsers:          getEnvInt("MAX_USERS", 0),
+			CacheListenNotify: getEnvBool("CACHE_LISTEN_NOTIFY", false),
+			CacheBypass:       getEnvBool("CACHE_BYPASS_ENABLED", false),
+			UpdateNullClears:  getEnvBool("UPDATE_NULL_CLEARS", false),
+		},
+		AccessLogConfig: AccessLogConfig{
+			Enabled:         getEnvBool("ACCESS_LOG", true),
+			SampleThreshold: getEnvInt("ACCESS_LOG_SAMPLE_THRESHOLD", 100),
+			SampleRate:      getEnvFloat("ACCESS_LOG_SAMPLE_RATE", 0.1),
+			SlowThreshold:   getEnvDuration("ACCESS_LOG_SLOW_THRESHOLD", time.Second),
+		},
+		RateLimitConfig: RateLimitConfig{
+			RequestsPerSecond: getEnvFloat("RATE_LIMIT_RPS", 0),
+			Burst:             getEnvInt("RATE_LIMIT_BURST", 0),
+		},
+		BreakerConfig: BreakerConfig{
+			MaxFailures:         uint32(getEnvInt("DB_BREAKER_MAX_FAILURES", 5)),
+			OpenTimeout:         getEnvDuration("DB_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+			HalfOpenMaxRequests: uint32(getEnvInt("DB_BREAKER_HALF_OPEN_REQUESTS", 1)),
+		},
+		ConcurrencyConfig: ConcurrencyConfig{
+			DBRequests:     getEnvInt("DB_CONCURRENCY_LIMIT", db.MaxOpenConns),
+			StressRequests: getEnvInt("STRESS_CONCURRENCY_LIMIT", 0),
+		},
+		PodConfig: PodConfig{
+			Name: getEnv("POD_NAME", hostname()),
+			Node: getEnv("NODE_NAME", ""),
+			IP:   getEnv("POD_IP", ""),
+		},
+		ChaosConfig: ChaosConfig{
+			Enabled:     getEnvBool("CHAOS_ENABLED", false),
+			DelayMin:    getEnvDuration("CHAOS_DELAY_MIN", 0),
+			DelayMax:    getEnvDuration("CHAOS_DELAY_MAX", 0),
+			ErrorRate:   getEnvFloat("CHAOS_ERROR_RATE", 0),
+			ErrorRoutes: getEnvList("CHAOS_ERROR_ROUTES", []string{"/api/"}),
+		},
+		CaptureConfig: CaptureConfig{
+			Enabled:      getEnvBool("DEBUG_CAPTURE", false),
+			SampleRate:   getEnvFloat("DEBUG_CAPTURE_SAMPLE_RATE", 0.1),
+			Size:         getEnvInt("DEBUG_CAPTURE_SIZE", 100),
+			MaxBodyBytes: getEnvInt("DEBUG_CAPTURE_MAX_BODY", 4096),
+		},
+		ReplayConfig: ReplayConfig{
+			Enabled:        getEnvBool("REPLAY_ENABLED", false),
+			MaxRequests:    getEnvInt("REPLAY_MAX_REQUESTS", 1000),
+			MaxConcurrency: getEnvInt("REPLAY_MAX_CONCURRENCY", 16),
+		},
+		StressConfig: StressConfig{
+			MemoryFraction:   getEnvFloat("STRESS_MEMORY_FRACTION", 0.5),
+			MemoryMaxMB:      getEnvInt("STRESS_MEMORY_MAX_MB", 512),
+			AsyncWorkers:     getEnvInt("STRESS_ASYNC_WORKERS", 2),
+			AsyncQueueSize:   getEnvInt("STRESS_ASYNC_QUEUE_SIZE", 100),
+			ProgressInterval: getEnvDuration("STRESS_PROGRESS_INTERVAL", time.Second),
+			DBQueryTimeout:   getEnvDuration("STRESS_DB_QUERY_TIMEOUT", 2*time.Second),
+		},
+		WebhookConfig: WebhookConfig{
+			URL:         getEnv("WEBHOOK_URL", ""),
+			Secret:      getEnv("WEBHOOK_SECRET", ""),
+			QueueSize:   getEnvInt("WEBHOOK_QUEUE_SIZE", 100),
+			MaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 3),
+			Timeout:     getEnvDuration("WEBHOOK_TIMEOUT", 5*time.Second),
+			Workers:     getEnvInt("WEBHOOK_WORKERS", 1),
+			RateLimit:   getEnvFloat("WEBHOOK_RATE_LIMIT", 0),
+		},
+	}, nil
 }
 
 func (c *DatabaseConfig) ConnectionString() string {
-	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		c.Host, c.Port, c.User, c.Password, c.DBName)
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		dsnValue(c.Host), dsnValue(c.Port), dsnValue(c.User), dsnValue(c.Password), dsnValue(c.DBName), dsnValue(c.SSLMode))
+	if c.ApplicationName != "" {
+		dsn += " application_name=" + dsnValue(c.ApplicationName)
+	}
+	if c.StatementTimeout > 0 {
+		dsn += fmt.Sprintf(" options='-c statement_timeout=%d'", c.StatementTimeout.Milliseconds())
+	}
+	return dsn
+}
+
+// dsnValue quotes v for a libpq key=value connection string when it is
+// empty or contains spaces, quotes or backslashes, as URL passwords often do.
+func dsnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
 }
 
 func (c *RedisConfig) Address() string {
 	return fmt.Sprintf("%s:%s", c.Host, c.Port)
 }
 
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList splits a comma-separated value, trimming blanks.
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// knownDeps are the dependency names REQUIRED_DEPS accepts.
+var knownDeps = map[string]bool{"db": true, "redis": true}
+
+// getEnvDeps reads a dependency list, rejecting unknown names so a typo
+// does not silently leave a dependency optional.
+func getEnvDeps(key string) ([]string, error) {
+	deps := getEnvList(key, nil)
+	for _, dep := range deps {
+		if !knownDeps[dep] {
+			return nil, fmt.Errorf("%s: unknown dependency %q (want db or redis)", key, dep)
+		}
+	}
+	return deps, nil
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,117 @@
+package config
+
+import "log/slog"
+
+const redacted = "***"
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// LogValue summarizes the effective configuration for a single startup log
+// line, with secrets redacted.
+func (c *Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Group("db",
+			"host", c.DatabaseConfig.Host,
+			"port", c.DatabaseConfig.Port,
+			"dbname", c.DatabaseConfig.DBName,
+			"sslmode", c.DatabaseConfig.SSLMode,
+			"application_name", c.DatabaseConfig.ApplicationName,
+			"user", c.DatabaseConfig.User,
+			"password", redact(c.DatabaseConfig.Password),
+			"max_open_conns", c.DatabaseConfig.MaxOpenConns,
+			"max_idle_conns", c.DatabaseConfig.MaxIdleConns,
+			"conn_max_lifetime", c.DatabaseConfig.ConnMaxLifetime,
+			"schema_check_strict", c.DatabaseConfig.SchemaCheckStrict,
+			"statement_timeout", c.DatabaseConfig.StatementTimeout,
+			"warmup_conns", c.DatabaseConfig.WarmupConns,
+			"warmup_timeout", c.DatabaseConfig.WarmupTimeout,
+			"prepared_statements", c.DatabaseConfig.PreparedStatements,
+			"concurrency_limit", c.ConcurrencyConfig.DBRequests,
+		),
+		slog.Group("db_read",
+			"host", c.ReadDatabaseConfig.Host,
+			"port", c.ReadDatabaseConfig.Port,
+			"dbname", c.ReadDatabaseConfig.DBName,
+		),
+		slog.Group("redis",
+			"addr", c.RedisConfig.Address(),
+			"tls", c.RedisConfig.TLS,
+			"slow_threshold", c.RedisConfig.SlowThreshold,
+			"password", redact(c.RedisConfig.Password),
+			"enabled", c.RedisConfig.Host != "",
+		),
+		slog.Group("pod",
+			"name", c.PodConfig.Name,
+			"node", c.PodConfig.Node,
+			"ip", c.PodConfig.IP,
+		),
+		slog.Group("server",
+			"port", c.ServerConfig.Port,
+			"shutdown_drain_delay", c.ServerConfig.ShutdownDrainDelay,
+			"shutdown_timeout", c.ServerConfig.ShutdownTimeout,
+			"interrupt_shutdown_timeout", c.ServerConfig.InterruptShutdownTimeout,
+			"shutdown_hook_timeout", c.ServerConfig.ShutdownHookTimeout,
+			"max_request_timeout", c.ServerConfig.MaxRequestTimeout,
+			"max_request_body_bytes", c.ServerConfig.MaxRequestBodyBytes,
+			"admin_token", redact(c.ServerConfig.AdminToken),
+			"jwt_secret", redact(c.ServerConfig.JWTSecret),
+			"stress_concurrency_limit", c.ConcurrencyConfig.StressRequests,
+			"stress_memory_fraction", c.StressConfig.MemoryFraction,
+			"stress_async_workers", c.StressConfig.AsyncWorkers,
+			"stress_async_queue_size", c.StressConfig.AsyncQueueSize,
+			"stress_progress_interval", c.StressConfig.ProgressInterval,
+			"stress_db_query_timeout", c.StressConfig.DBQueryTimeout,
+		),
+		slog.Group("api",
+			"cache_ttl", c.APIConfig.CacheTTL,
+			"cache_warm", c.APIConfig.CacheWarm,
+			"cache_soft_ttl", c.APIConfig.CacheSoftTTL,
+			"cache_headers", c.APIConfig.CacheHeaders,
+			"l1_cache_size", c.APIConfig.L1CacheSize,
+			"l1_cache_ttl", c.APIConfig.L1CacheTTL,
+			"default_user_name", c.APIConfig.DefaultUserName,
+			"cache_listen_notify", c.APIConfig.CacheListenNotify,
+			"cache_bypass", c.APIConfig.CacheBypass,
+			"update_null_clears", c.APIConfig.UpdateNullClears,
+			"max_users", c.APIConfig.MaxUsers,
+		),
+		slog.Group("features",
+			"read_replica", c.ReadDatabaseConfig.Host != "",
+			"maintenance_mode", c.ServerConfig.MaintenanceMode,
+			"response_envelope", c.APIConfig.ResponseEnvelope,
+			"json_naming", c.APIConfig.JSONNaming,
+			"trailing_slash_mode", c.ServerConfig.TrailingSlashMode,
+			"rate_limit_rps", c.RateLimitConfig.RequestsPerSecond,
+			"rate_limit_burst", c.RateLimitConfig.Burst,
+			"db_breaker_max_failures", c.BreakerConfig.MaxFailures,
+			"webhook", c.WebhookConfig.URL != "",
+			"webhook_workers", c.WebhookConfig.Workers,
+			"webhook_rate_limit", c.WebhookConfig.RateLimit,
+			"user_agent_filter", c.ServerConfig.UserAgentFilter,
+			"chaos", c.ChaosConfig.Enabled,
+			"replay", c.ReplayConfig.Enabled,
+			"debug_capture", c.CaptureConfig.Enabled,
+			"debug_capture_sample_rate", c.CaptureConfig.SampleRate,
+			"readiness_pool_check", c.ServerConfig.ReadinessPoolCheck,
+			"required_deps", c.ServerConfig.RequiredDeps,
+			"health_degraded_latency", c.ServerConfig.HealthDegradedLatency,
+			"disabled_endpoints", c.ServerConfig.DisabledEndpoints,
+			"json_errors", c.ServerConfig.JSONErrors,
+			"trusted_proxies", c.ServerConfig.TrustedProxies,
+			"admin_allowed_cidrs", c.ServerConfig.AdminAllowedCIDRs,
+			"shed_target_latency", c.ServerConfig.ShedTargetLatency,
+			"shed_max_rate", c.ServerConfig.ShedMaxRate,
+			"cgroup_gomaxprocs", c.ServerConfig.CgroupGOMAXPROCS,
+			"server_timing", c.ServerConfig.ServerTiming,
+			"access_log", c.AccessLogConfig.Enabled,
+			"access_log_sample_threshold", c.AccessLogConfig.SampleThreshold,
+			"access_log_sample_rate", c.AccessLogConfig.SampleRate,
+			"access_log_slow_threshold", c.AccessLogConfig.SlowThreshold,
+		),
+	)
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// applyDatabaseURL overrides c's connection fields with those of a
+// postgres:// URL such as managed platforms provide in DATABASE_URL.
+func applyDatabaseURL(c *DatabaseConfig, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid DATABASE_URL: %w", parseReason(err))
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return fmt.Errorf("invalid DATABASE_URL: scheme must be postgres or postgresql, got %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("invalid DATABASE_URL: missing host")
+	}
+
+	c.Host = u.Hostname()
+	if port := u.Port(); port != "" {
+		c.Port = port
+	}
+	if u.User != nil {
+		c.User = u.User.Username()
+		c.Password, _ = u.User.Password()
+	}
+	if name := strings.TrimPrefix(u.Path, "/"); name != "" {
+		c.DBName = name
+	}
+	if mode := u.Query().Get("sslmode"); mode != "" {
+		c.SSLMode = mode
+	}
+	return nil
+}
+
+// applyRedisURL overrides c's connection fields with those of a redis:// or
+// rediss:// URL, as given in REDIS_URL.
+func applyRedisURL(c *RedisConfig, raw string) error {
+	opts, err := redis.ParseURL(raw)
+	if err != nil {
+		return fmt.Errorf("invalid REDIS_URL: %w", parseReason(err))
+	}
+	host, port, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+
+	c.Host, c.Port = host, port
+	c.Username, c.Password = opts.Username, opts.Password
+	c.DB = opts.DB
+	c.TLS = opts.TLSConfig != nil
+	return nil
+}
+
+// parseReason strips the *url.Error wrapper, whose message quotes the whole
+// URL, password included, leaving only why parsing failed.
+func parseReason(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AccessLog logs one line per request. Up to threshold requests per second
+// are all logged; beyond that only a rate fraction is, so heavy load doesn't
+// flood the log pipeline. Errors (4xx/5xx) and requests slower than slow are
+// always logged.
+type AccessLog struct {
+	threshold int
+	rate      float64
+	slow      time.Duration
+
+	mu     sync.Mutex
+	window time.Time
+	count  int
+}
+
+// NewAccessLog returns an access logger; a threshold of 0 samples every
+// request and a rate of 1 logs them all.
+func NewAccessLog(threshold int, rate float64, slow time.Duration) *AccessLog {
+	return &AccessLog{threshold: threshold, rate: rate, slow: slow}
+}
+
+// Middleware logs requests after they are served. Install it inside
+// ClientIPMiddleware so the resolved client IP is logged.
+func (a *AccessLog) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rw, r)
+
+		elapsed := time.Since(start)
+		slow := a.slow > 0 && elapsed >= a.slow
+		sampled := a.sampled(start)
+		switch {
+		case rw.status >= 400, slow:
+		case probePaths[r.URL.Path], !sampled:
+			return
+		}
+		slog.Info("Request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rw.status,
+			"bytes", rw.size,
+			"duration", elapsed,
+			"client_ip", remoteIP(r),
+			"request_id", RequestID(r.Context()),
+			"slow", slow,
+		)
+	})
+}
+
+// sampled counts a request in the current one-second window and reports
+// whether it should be logged as a regular request.
+func (a *AccessLog) sampled(now time.Time) bool {
+	a.mu.Lock()
+	if now.Sub(a.window) >= time.Second {
+		a.window = now
+		a.count = 0
+	}
+	a.count++
+	under := a.count <= a.threshold
+	a.mu.Unlock()
+	return under || rand.Float64() < a.rate
+}
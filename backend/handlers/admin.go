@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// RequireAdmin only lets through requests bearing the configured admin token.
+// With no token configured, admin endpoints are disabled entirely.
+func RequireAdmin(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.Error(w, "Admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminAllowlist restricts the endpoints it wraps to clients whose IP, as
+// resolved by ClientIPMiddleware, falls in allowed (CIDRs or bare IPs).
+// Other clients get 403 and are logged. An empty list allows everyone.
+func AdminAllowlist(allowed []string) (func(http.Handler) http.Handler, error) {
+	if len(allowed) == 0 {
+		return func(next http.Handler) http.Handler { return next }, nil
+	}
+	isAllowed, err := ipMatcher(allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := remoteIP(r); !isAllowed(ip) {
+				log.Printf("Denied admin request %s %s from %s: not in allowlist", r.Method, r.URL.Path, ip)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// Maintenance blocks writes with 503 while enabled. Reads, admin endpoints
+// and readiness keep working so the pod stays in rotation.
+type Maintenance struct {
+	enabled    atomic.Bool
+	retryAfter time.Duration
+}
+
+func NewMaintenance(enabled bool, retryAfter time.Duration) *Maintenance {
+	m := &Maintenance{retryAfter: retryAfter}
+	m.enabled.Store(enabled)
+	if enabled {
+		log.Println("Maintenance mode enabled at startup")
+	}
+	return m
+}
+
+func (m *Maintenance) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.enabled.Load() && isMutating(r.Method) && !readOnlyPosts[r.URL.Path] && !strings.HasPrefix(r.URL.Path, "/api/admin/") {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(m.retryAfter)))
+			http.Error(w, "Service is in maintenance mode; writes are temporarily disabled", http.StatusServiceUnavailable)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP reports the current mode on GET and toggles it on POST with
+// {"enabled": true|false}.
+func (m *Maintenance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodPost {
+		var req models.MaintenanceStatus
+		if err := decodeJSON(r, &req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if m.enabled.Swap(req.Enabled) != req.Enabled {
+			log.Printf("Maintenance mode toggled: enabled=%t (from %s)", req.Enabled, remoteIP(r))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, models.MaintenanceStatus{Enabled: m.enabled.Load()})
+}
+
+// readOnlyPosts are POST endpoints that only read, so maintenance mode lets
+// them through.
+var readOnlyPosts = map[string]bool{
+	"/api/users/batch-get": true,
+	"/api/users/validate":  true,
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
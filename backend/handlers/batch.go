@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+
+	"github.com/lib/pq"
+)
+
+const maxBatchGet = 500
+
+// BatchGetUsers resolves many ids at once: hits come from one MGET and all
+// misses from a single ANY($1) query, after which the misses are cached.
+func (h *UserHandler) BatchGetUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.BatchGetRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxBatchGet {
+		http.Error(w, fmt.Sprintf("Batch must contain between 1 and %d ids", maxBatchGet), http.StatusBadRequest)
+		return
+	}
+
+	ids := dedupeIDs(req.IDs)
+	response := models.BatchGetResponse{
+		Users:    make(map[int]json.RawMessage, len(ids)),
+		NotFound: []int{},
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = userKey(id)
+	}
+
+	var misses []int
+	start := time.Now()
+	cached, err := h.Cache.MGet(h.Ctx, keys...)
+	recordTiming(r.Context(), timingCache, time.Since(start))
+	for i, id := range ids {
+		if err == nil {
+			if raw := cached[i]; raw != nil {
+				payload, _ := decodeCacheEntry(raw)
+				response.Users[id] = payload
+				continue
+			}
+		}
+		misses = append(misses, id)
+	}
+
+	if len(misses) > 0 {
+		var users []models.User
+		err := h.withReader(func(db *sql.DB) error {
+			defer observeQuery(r.Context(), opSelectUsersBatch, time.Now())
+			rows, err := db.QueryContext(r.Context(),
+				"SELECT "+userColumns+" FROM users WHERE id = ANY($1)", pq.Array(misses))
+			if err != nil {
+				return err
+			}
+			users, err = scanUsers(rows)
+			return err
+		})
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		entries := make(map[string][]byte, len(users))
+		for _, user := range users {
+			userJSON, err := safeEncode(user)
+			if err != nil {
+				continue
+			}
+			response.Users[user.ID] = userJSON
+			key := userKey(user.ID)
+			observeCacheSize(key, userJSON)
+			entries[key] = encodeCacheEntry(userJSON, time.Now())
+		}
+		if !h.oom.paused() {
+			h.oom.observe(h.Cache.SetMany(h.Ctx, entries, h.cacheTTL()))
+		}
+
+		for _, id := range misses {
+			if _, ok := response.Users[id]; !ok {
+				response.NotFound = append(response.NotFound, id)
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func dedupeIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	sort.Ints(unique)
+	return unique
+}
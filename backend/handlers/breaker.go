@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"k8s-autoscale-webapp/config"
+	"k8s-autoscale-webapp/metrics"
+
+	"github.com/sony/gobreaker"
+)
+
+// NewDBBreaker builds the circuit breaker guarding user handler DB calls. It
+// returns nil, meaning no breaker, when MaxFailures is 0.
+func NewDBBreaker(cfg config.BreakerConfig) *gobreaker.CircuitBreaker {
+	if cfg.MaxFailures == 0 {
+		return nil
+	}
+
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        "database",
+		MaxRequests: cfg.HalfOpenMaxRequests,
+		Timeout:     cfg.OpenTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= cfg.MaxFailures
+		},
+		// Missing rows, duplicate emails, write conflicts and abandoned or
+		// client-timed-out requests say nothing about DB health; counting
+		// them would let one client with an aggressive X-Request-Timeout, or
+		// a few repeated creates, open the breaker for everyone. Canceled statements (57014) are excluded too: pq reports
+		// a client cancellation the same way as an expired
+		// DB_STATEMENT_TIMEOUT, so the two cannot be told apart.
+		IsSuccessful: func(err error) bool {
+			return err == nil || errors.Is(err, sql.ErrNoRows) || isUniqueViolation(err) || isTxConflict(err) ||
+				errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || isQueryCanceled(err)
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			log.Printf("Circuit breaker %s: %s -> %s", name, from, to)
+			metrics.DBBreakerState.Set(float64(to))
+		},
+	})
+}
+
+// guard runs fn through the DB circuit breaker, failing fast with
+// gobreaker.ErrOpenState while the breaker is open. Outcomes are reported
+// to the failover monitor.
+func (s *pgStore) guard(fn func() error) error {
+	if s.breaker == nil {
+		err := fn()
+		s.observeFailover(err)
+		return err
+	}
+
+	_, err := s.breaker.Execute(func() (interface{}, error) {
+		err := fn()
+		s.observeFailover(err)
+		return nil, err
+	})
+	return err
+}
+
+// observeFailover reports a DB outcome to the failover monitor. Missing rows
+// are a successful round trip.
+func (s *pgStore) observeFailover(err error) {
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	s.failover.Observe(err)
+}
+
+func isBreakerRejection(err error) bool {
+	return errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"k8s-autoscale-webapp/cache"
+	"k8s-autoscale-webapp/config"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/sony/gobreaker"
+)
+
+func TestBreakerIgnoresDuplicateEmails(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := config.BreakerConfig{MaxFailures: 5, OpenTimeout: time.Minute, HalfOpenMaxRequests: 1}
+	breaker := NewDBBreaker(cfg)
+	h := NewUserHandler(db, nil, cache.NoCache{}, context.Background(), config.APIConfig{}, breaker, nil)
+
+	stmt := mock.ExpectPrepare(queryInsertUser)
+	for i := 0; i <= int(cfg.MaxFailures); i++ {
+		stmt.ExpectQuery().WithArgs("Ada", "ada@example.com").
+			WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"})
+	}
+	stmt.ExpectQuery().WithArgs("Bob", "bob@example.com").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "created_at", "version"}).AddRow(4, testCreatedAt, 1))
+
+	for i := 0; i <= int(cfg.MaxFailures); i++ {
+		createUser(h, `{"name":"Ada","email":"ada@example.com"}`)
+	}
+	if state := breaker.State(); state != gobreaker.StateClosed {
+		t.Fatalf("breaker state = %s after duplicate creates, want closed", state)
+	}
+	if rec := createUser(h, `{"name":"Bob","email":"bob@example.com"}`); rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	expectMet(t, mock)
+}
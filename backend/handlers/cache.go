@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// userListsKey is a Redis set indexing every cached variant of the user
+// list (e.g. per field projection) so they can be invalidated together.
+const userListsKey = "users:lists"
+
+// userSubjectsKey is a Redis set indexing every entry scoped to an
+// authenticated subject (e.g. /api/users/me) so they can be invalidated
+// together.
+const userSubjectsKey = "users:subjects"
+
+// cacheIndexed caches payload under key and registers key in the index set.
+func (h *UserHandler) cacheIndexed(index, key string, payload []byte) {
+	if h.oom.paused() {
+		return
+	}
+	observeCacheSize(key, payload)
+	h.oom.observe(h.Cache.SetIndexed(h.Ctx, index, key, encodeCacheEntry(payload, time.Now()), h.cacheTTL()))
+}
+
+// setCacheHeaders reports via X-Cache whether the response came from Redis
+// and, on hits, the key's remaining TTL in seconds via X-Cache-TTL. It is a
+// no-op unless cache debug headers are enabled.
+func (h *UserHandler) setCacheHeaders(w http.ResponseWriter, key string, hit bool) {
+	if !h.Config.CacheHeaders {
+		return
+	}
+	if !hit {
+		w.Header().Set("X-Cache", "MISS")
+		return
+	}
+
+	w.Header().Set("X-Cache", "HIT")
+	if ttl, err := h.Cache.TTL(h.Ctx, key); err == nil && ttl > 0 {
+		w.Header().Set("X-Cache-TTL", strconv.Itoa(ceilSeconds(ttl)))
+	}
+}
+
+// bypassCache reports whether r asked to skip the cached copy, via
+// Cache-Control: no-cache or ?nocache=true, and bypassing is enabled. It
+// marks such responses with X-Cache: BYPASS.
+func (h *UserHandler) bypassCache(w http.ResponseWriter, r *http.Request) bool {
+	if !h.Config.CacheBypass {
+		return false
+	}
+	if !strings.Contains(r.Header.Get("Cache-Control"), "no-cache") && r.URL.Query().Get("nocache") != "true" {
+		return false
+	}
+	w.Header().Set("X-Cache", "BYPASS")
+	return true
+}
+
+// invalidateUsers drops every cached user list and subject-scoped entry plus
+// the per-user entries for ids, in Redis and in every pod's L1.
+func (h *UserHandler) invalidateUsers(ids ...int) {
+	keys := []string{userListKey(), userListsKey, userSubjectsKey}
+	for _, index := range []string{userListsKey, userSubjectsKey} {
+		members, _ := h.Cache.Members(h.Ctx, index)
+		keys = append(keys, members...)
+	}
+	for _, id := range ids {
+		keys = append(keys, userKey(id))
+	}
+	h.deleteKeys(keys)
+	h.l1Invalidate(ids)
+}
+
+// userEmailKey is the shared entry of a user looked up by email.
+func userEmailKey(email string) string {
+	return queryKey("user:email", queryGetUserByEmail, email)
+}
+
+// invalidateUserEmails drops the by-email entries for emails.
+func (h *UserHandler) invalidateUserEmails(emails ...string) {
+	keys := make([]string, 0, len(emails))
+	for _, email := range emails {
+		if email != "" {
+			keys = append(keys, userEmailKey(email))
+		}
+	}
+	h.deleteKeys(keys)
+}
+
+func (h *UserHandler) deleteKeys(keys []string) error {
+	return h.Cache.Del(h.Ctx, keys...)
+}
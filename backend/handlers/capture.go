@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// redactedValue replaces secrets in captured requests.
+const redactedValue = "***"
+
+// secretHeaders are never captured in clear.
+var secretHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+	"X-Api-Key":     true,
+}
+
+// secretField reports whether a JSON field or query parameter name looks
+// like it holds a secret.
+func secretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"password", "secret", "token", "authorization", "api_key", "apikey"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// BodyCapture keeps the request and response bodies of a sample of failing
+// (4xx/5xx) requests in a bounded ring buffer, served at /debug/requests, to
+// reproduce payloads that fail intermittently under load.
+type BodyCapture struct {
+	rate    float64
+	maxBody int
+
+	mu      sync.Mutex
+	entries []models.CapturedRequest
+	next    int
+	full    bool
+}
+
+// NewBodyCapture keeps the last size failing requests out of a rate fraction
+// of all requests, each body truncated to maxBody bytes.
+func NewBodyCapture(size, maxBody int, rate float64) *BodyCapture {
+	return &BodyCapture{rate: rate, maxBody: maxBody, entries: make([]models.CapturedRequest, max(size, 1))}
+}
+
+func (c *BodyCapture) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rand.Float64() >= c.rate {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Read the start of the body up front so it is captured even if
+		// the handler fails before reading it.
+		prefix, _ := io.ReadAll(io.LimitReader(r.Body, int64(c.maxBody)+1))
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(prefix), r.Body), r.Body}
+		cw := &captureWriter{
+			responseRecorder: responseRecorder{ResponseWriter: w, status: http.StatusOK},
+			body:             limitedBuffer{max: c.maxBody},
+		}
+		start := time.Now()
+
+		next.ServeHTTP(cw, r)
+
+		if cw.status < 400 {
+			return
+		}
+		entry := models.CapturedRequest{
+			Time:              start,
+			RequestID:         RequestID(r.Context()),
+			Method:            r.Method,
+			Path:              r.URL.Path,
+			Query:             redactQuery(r.URL.Query()),
+			Status:            cw.status,
+			Duration:          time.Since(start).String(),
+			Headers:           redactHeaders(r.Header),
+			RequestBody:       redactBody(prefix[:min(len(prefix), c.maxBody)]),
+			RequestTruncated:  len(prefix) > c.maxBody,
+			ResponseBody:      redactBody(cw.body.buf.Bytes()),
+			ResponseTruncated: cw.body.truncated,
+		}
+		c.mu.Lock()
+		c.entries[c.next] = entry
+		c.next = (c.next + 1) % len(c.entries)
+		c.full = c.full || c.next == 0
+		c.mu.Unlock()
+	})
+}
+
+// ServeHTTP lists the captured requests, newest first.
+func (c *BodyCapture) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	n := c.next
+	if c.full {
+		n = len(c.entries)
+	}
+	list := make([]models.CapturedRequest, 0, n)
+	for i := 1; i <= n; i++ {
+		list = append(list, c.entries[(c.next-i+len(c.entries))%len(c.entries)])
+	}
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, list)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// captureWriter copies the start of the response body aside.
+type captureWriter struct {
+	responseRecorder
+	body limitedBuffer
+}
+
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	cw.body.Write(p)
+	return cw.responseRecorder.Write(p)
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	room := b.max - b.buf.Len()
+	if len(p) > room {
+		b.truncated = true
+		p = p[:max(room, 0)]
+	}
+	b.buf.Write(p)
+	return len(p), nil
+}
+
+func redactHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+	for name, values := range h {
+		if secretHeaders[name] || secretField(name) {
+			headers[name] = redactedValue
+		} else {
+			headers[name] = strings.Join(values, ", ")
+		}
+	}
+	return headers
+}
+
+func redactQuery(query url.Values) string {
+	for name := range query {
+		if secretField(name) {
+			query.Set(name, redactedValue)
+		}
+	}
+	return query.Encode()
+}
+
+// secretPair matches a quoted secret field and its value, for bodies that
+// cannot be parsed as JSON (e.g. truncated ones). A string value may be cut
+// anywhere, even after a backslash; other values run to the next delimiter.
+var secretPair = regexp.MustCompile(`(?i)("[^"]*(?:password|secret|token|authorization|api_?key)[^"]*"\s*:\s*)(?:"(?:[^"\\]|\\.?)*"?|[^\s,}\]]+)`)
+
+// redactBody masks secret fields of a JSON body, falling back to pattern
+// matching when the body is not valid JSON.
+func redactBody(body []byte) string {
+	var v any
+	if json.Unmarshal(body, &v) != nil {
+		return secretPair.ReplaceAllString(string(body), `$1"`+redactedValue+`"`)
+	}
+	redacted, err := json.Marshal(redactJSON(v))
+	if err != nil {
+		return string(body)
+	}
+	return string(redacted)
+}
+
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if secretField(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactJSON(value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactJSON(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"flat", `{"email":"a@b.c","password":"hunter2"}`, `{"email":"a@b.c","password":"***"}`},
+		{"nested", `{"user":{"name":"Ada","api_key":"k1"},"tokens":["t1"]}`, `{"tokens":"***","user":{"api_key":"***","name":"Ada"}}`},
+		{"in array", `[{"client_secret":"s"},{"id":1}]`, `[{"client_secret":"***"},{"id":1}]`},
+		{"non-string value", `{"token":12345}`, `{"token":"***"}`},
+		{"not secret", `{"name":"Ada"}`, `{"name":"Ada"}`},
+		{"truncated mid-value", `{"name":"Ada","password":"hunt`, `{"name":"Ada","password":"***"`},
+		{"truncated after escape", `{"password":"ab\`, `{"password":"***"`},
+		{"truncated with escaped quote", `{"password":"a\"b","name":"Ad`, `{"password":"***","name":"Ad`},
+		{"truncated nested", `{"user":{"ApiKey":"k1"},"Authorization":"Bearer x`, `{"user":{"ApiKey":"***"},"Authorization":"***"`},
+		{"truncated number", `{"secret_pin":4711,"na`, `{"secret_pin":"***","na`},
+	}
+	for _, tt := range tests {
+		if got := redactBody([]byte(tt.body)); got != tt.want {
+			t.Errorf("%s: redactBody = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	query := url.Values{"id": {"7"}, "access_token": {"abc"}, "APIKEY": {"k"}}
+	got, _ := url.ParseQuery(redactQuery(query))
+	if got.Get("id") != "7" || got.Get("access_token") != redactedValue || got.Get("APIKEY") != redactedValue {
+		t.Errorf("redactQuery = %v, want only id in clear", got)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer abc")
+	h.Set("X-Admin-Token", "admin")
+	h.Set("Cookie", "session=1")
+	h.Set("X-Api-Key", "k")
+	h.Add("Accept", "application/json")
+	h.Add("Accept", "text/csv")
+
+	got := redactHeaders(h)
+	for _, name := range []string{"Authorization", "X-Admin-Token", "Cookie", "X-Api-Key"} {
+		if got[name] != redactedValue {
+			t.Errorf("%s = %q, want %q", name, got[name], redactedValue)
+		}
+	}
+	if got["Accept"] != "application/json, text/csv" {
+		t.Errorf("Accept = %q", got["Accept"])
+	}
+	for name, value := range got {
+		if strings.Contains(value, "abc") || strings.Contains(value, "admin") {
+			t.Errorf("%s leaks %q", name, value)
+		}
+	}
+}
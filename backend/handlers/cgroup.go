@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cgroupMemoryFiles lists the container memory limit locations for cgroup v2
+// and v1.
+var cgroupMemoryFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// unlimitedCgroupMemory is the threshold above which a cgroup v1 limit is
+// treated as unset (the kernel reports a page-aligned max int64).
+const unlimitedCgroupMemory = 1 << 62
+
+// CgroupMemoryLimit returns the container memory limit in bytes, or 0 when
+// no limit is set or it cannot be read.
+func CgroupMemoryLimit() int64 {
+	for _, path := range cgroupMemoryFiles {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSpace(string(raw))
+		if value == "max" {
+			return 0
+		}
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit >= unlimitedCgroupMemory {
+			return 0
+		}
+		return limit
+	}
+	return 0
+}
+
+// CgroupCPULimit returns the container CPU limit in cores, from the CFS
+// quota and period of cgroup v2 (cpu.max) or v1, or 0 when no limit is set
+// or it cannot be read.
+func CgroupCPULimit() float64 {
+	if raw, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		quota, period, _ := strings.Cut(strings.TrimSpace(string(raw)), " ")
+		return cpuLimit(quota, period)
+	}
+	quota, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0
+	}
+	period, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0
+	}
+	return cpuLimit(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// cpuLimit divides a CFS quota by its period; "max" and -1 mean no limit.
+func cpuLimit(quota, period string) float64 {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+// CgroupGOMAXPROCS is the GOMAXPROCS matching the container CPU limit: the
+// limit rounded down, at least 1. It is 0 when no limit is set.
+func CgroupGOMAXPROCS() int {
+	limit := CgroupCPULimit()
+	if limit == 0 {
+		return 0
+	}
+	return max(1, int(limit))
+}
+
+// StressMemoryCeiling returns the largest memory stress allocation in MB:
+// fraction of the cgroup limit when one is set, fallbackMB otherwise.
+func StressMemoryCeiling(fraction float64, fallbackMB int) int {
+	limit := CgroupMemoryLimit()
+	if limit == 0 {
+		return fallbackMB
+	}
+	return int(float64(limit) * fraction / (1 << 20))
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s-autoscale-webapp/metrics"
+	"k8s-autoscale-webapp/models"
+)
+
+// DelayInjector holds every non-probe request for a random duration in
+// [min, max] before handling it, to exercise client timeouts and autoscaling
+// against a slow backend. A zero max disables it.
+type DelayInjector struct {
+	mu       sync.RWMutex
+	min, max time.Duration
+}
+
+func NewDelayInjector(minDelay, maxDelay time.Duration) *DelayInjector {
+	d := &DelayInjector{}
+	d.set(minDelay, maxDelay)
+	if maxDelay > 0 {
+		log.Printf("Delay injection enabled at startup: %s-%s", d.min, d.max)
+	}
+	return d
+}
+
+func (d *DelayInjector) set(minDelay, maxDelay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.min, d.max = minDelay, maxDelay
+}
+
+func (d *DelayInjector) next() time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.max <= d.min {
+		return d.max
+	}
+	return d.min + rand.N(d.max-d.min+1)
+}
+
+func (d *DelayInjector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay := d.next(); delay > 0 && !probePaths[r.URL.Path] && !strings.HasPrefix(r.URL.Path, "/api/admin/") {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				writeContextError(w, r.Context().Err())
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP reports the current range on GET and changes it on POST with
+// {"min": "100ms", "max": "500ms"}; a max of "0s" turns injection off.
+func (d *DelayInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodPost {
+		var req models.ChaosDelay
+		if err := decodeJSON(r, &req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		minDelay, errMin := parseOptionalDuration(req.Min)
+		maxDelay, errMax := parseOptionalDuration(req.Max)
+		if errMin != nil || errMax != nil || minDelay < 0 || maxDelay < minDelay {
+			http.Error(w, "Invalid delay: min and max must be durations with 0 <= min <= max", http.StatusBadRequest)
+			return
+		}
+		d.set(minDelay, maxDelay)
+		log.Printf("Delay injection set to %s-%s (from %s)", minDelay, maxDelay, remoteIP(r))
+	}
+
+	d.mu.RLock()
+	status := models.ChaosDelay{Min: d.min.String(), Max: d.max.String()}
+	d.mu.RUnlock()
+	writeJSON(w, http.StatusOK, status)
+}
+
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// ErrorInjector answers a fraction of requests under its route prefixes with
+// 500, to exercise client retries and error-rate alerts. Injected errors are
+// counted in chaos_injected_errors_total so they can be told apart from real
+// ones.
+type ErrorInjector struct {
+	mu     sync.RWMutex
+	rate   float64
+	routes []string
+}
+
+func NewErrorInjector(rate float64, routes []string) *ErrorInjector {
+	e := &ErrorInjector{rate: rate, routes: routes}
+	if rate > 0 {
+		log.Printf("Error injection enabled at startup: rate=%g routes=%v", rate, routes)
+	}
+	return e
+}
+
+// match returns the route prefix to fail path under, or "" to let it through.
+func (e *ErrorInjector) match(path string) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.rate <= 0 || rand.Float64() >= e.rate {
+		return ""
+	}
+	for _, route := range e.routes {
+		if strings.HasPrefix(path, route) {
+			return route
+		}
+	}
+	return ""
+}
+
+func (e *ErrorInjector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !probePaths[r.URL.Path] && !strings.HasPrefix(r.URL.Path, "/api/admin/") {
+			if route := e.match(r.URL.Path); route != "" {
+				metrics.ChaosInjectedErrors.WithLabelValues(route).Inc()
+				w.Header().Set("X-Chaos-Injected", "true")
+				http.Error(w, "Injected failure", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP reports the current settings on GET and changes them on POST
+// with {"rate": 0.1, "routes": ["/api/users"]}; omitted routes are kept.
+func (e *ErrorInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodPost {
+		var req models.ChaosErrors
+		if err := decodeJSON(r, &req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if req.Rate < 0 || req.Rate > 1 {
+			http.Error(w, "Invalid rate: must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+
+		e.mu.Lock()
+		e.rate = req.Rate
+		if req.Routes != nil {
+			e.routes = req.Routes
+		}
+		e.mu.Unlock()
+		log.Printf("Error injection set to rate=%g routes=%v (from %s)", req.Rate, req.Routes, remoteIP(r))
+	}
+
+	e.mu.RLock()
+	status := models.ChaosErrors{Rate: e.rate, Routes: e.routes}
+	e.mu.RUnlock()
+	writeJSON(w, http.StatusOK, status)
+}
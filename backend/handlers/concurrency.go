@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// ConcurrencyLimiter caps how many requests run a group of handlers at once.
+// Requests over the limit are shed with 503 instead of queueing, so a flood
+// on one group (e.g. DB-bound listing) cannot starve the others. The limit
+// can be changed at runtime: lowering it lets in-flight requests finish and
+// sheds new ones until the count drops below the new limit.
+type ConcurrencyLimiter struct {
+	mu sync.Mutex
+	// limit of 0 means unlimited.
+	limit   int
+	running int
+}
+
+// NewConcurrencyLimiter treats limit <= 0 as unlimited.
+func NewConcurrencyLimiter(limit int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{limit: max(limit, 0)}
+}
+
+func (l *ConcurrencyLimiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.acquire() {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Server busy, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		defer l.release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *ConcurrencyLimiter) acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit > 0 && l.running >= l.limit {
+		return false
+	}
+	l.running++
+	return true
+}
+
+func (l *ConcurrencyLimiter) release() {
+	l.mu.Lock()
+	l.running--
+	l.mu.Unlock()
+}
+
+// SetLimit changes the limit; 0 removes it.
+func (l *ConcurrencyLimiter) SetLimit(limit int) {
+	l.mu.Lock()
+	l.limit = limit
+	l.mu.Unlock()
+}
+
+func (l *ConcurrencyLimiter) status() (limit, running int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit, l.running
+}
+
+// ConcurrencyAdmin exposes named limiters under /api/admin/concurrency.
+type ConcurrencyAdmin struct {
+	groups map[string]*ConcurrencyLimiter
+}
+
+func NewConcurrencyAdmin(groups map[string]*ConcurrencyLimiter) *ConcurrencyAdmin {
+	return &ConcurrencyAdmin{groups: groups}
+}
+
+// ServeHTTP lists every group's limit on GET. POST ?limit=N&group=G resizes
+// group G (default "db"), with 0 meaning unlimited, and returns it.
+func (a *ConcurrencyAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		names := make([]string, 0, len(a.groups))
+		for name := range a.groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		list := make([]models.ConcurrencyLimit, 0, len(names))
+		for _, name := range names {
+			list = append(list, a.snapshot(name))
+		}
+		writeJSON(w, http.StatusOK, list)
+		return
+	}
+
+	group := r.URL.Query().Get("group")
+	if group == "" {
+		group = "db"
+	}
+	limiter, ok := a.groups[group]
+	if !ok {
+		http.Error(w, "Unknown concurrency group: "+group, http.StatusNotFound)
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 0 {
+		http.Error(w, "Invalid limit: must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+
+	limiter.SetLimit(limit)
+	log.Printf("Concurrency limit for %s set to %d (from %s)", group, limit, remoteIP(r))
+	writeJSON(w, http.StatusOK, a.snapshot(group))
+}
+
+func (a *ConcurrencyAdmin) snapshot(group string) models.ConcurrencyLimit {
+	limit, running := a.groups[group].status()
+	return models.ConcurrencyLimit{Group: group, Limit: limit, InFlight: running}
+}
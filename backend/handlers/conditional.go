@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+const (
+	queryInsertUserIfNotExists = "INSERT INTO users (name, email) VALUES ($1, $2) ON CONFLICT (email) DO NOTHING RETURNING id, created_at, version"
+	queryGetUserByEmail        = "SELECT " + userColumns + " FROM users WHERE email = $1"
+)
+
+// createUserIfNotExists serves POST /api/users?if_not_exists=true: it
+// inserts req unless a user with its email exists, answering 201 for a new
+// user and 200 with the existing one otherwise.
+func (h *UserHandler) createUserIfNotExists(w http.ResponseWriter, r *http.Request, req models.CreateUserRequest) {
+	user := models.UpsertedUser{User: models.User{Name: req.Name, Email: req.Email}}
+	err := h.guard(func() error {
+		// The existing row can be deleted between the two statements; the
+		// insert then succeeds on the second pass.
+		for attempt := 0; attempt < 2; attempt++ {
+			err := h.insertUserIfNotExists(r, req, &user)
+			if err != sql.ErrNoRows {
+				return err
+			}
+		}
+		return fmt.Errorf("user %s changed concurrently, retry the request", req.Email)
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if !user.Inserted {
+		writeJSON(w, http.StatusOK, user)
+		return
+	}
+
+	h.invalidateUsers()
+	h.usersAdded(1)
+	h.notifier.Notify("user.created", user.User)
+
+	w.Header().Set("Location", fmt.Sprintf("/api/users/%d", user.ID))
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// insertUserIfNotExists tries the insert and, on conflict, reads the
+// existing user by email. It returns sql.ErrNoRows when neither finds a row.
+func (h *UserHandler) insertUserIfNotExists(r *http.Request, req models.CreateUserRequest, user *models.UpsertedUser) error {
+	err := func() error {
+		defer observeQuery(r.Context(), opInsertUser, time.Now())
+		return h.DB.QueryRowContext(r.Context(), queryInsertUserIfNotExists, req.Name, req.Email).
+			Scan(&user.ID, &user.CreatedAt, &user.Version)
+	}()
+	if err != sql.ErrNoRows {
+		user.Inserted = err == nil
+		return err
+	}
+
+	// The follow-up select runs as a new statement, so it sees a row
+	// committed by the concurrent insert that caused the conflict.
+	defer observeQuery(r.Context(), opSelectUserByEmail, time.Now())
+	user.Inserted = false
+	return h.DB.QueryRowContext(r.Context(), queryGetUserByEmail, req.Email).Scan(userDest(&user.User)...)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// csvPaths are the endpoints JSONOnlyMiddleware lets through for clients
+// asking for text/csv.
+var csvPaths = map[string]bool{
+	"/api/users": true,
+}
+
+// csvFlushRows is how many rows are written between flushes to the client.
+const csvFlushRows = 500
+
+// wantsCSV reports whether the Accept header lists text/csv.
+func wantsCSV(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(part, ";")
+		if strings.EqualFold(strings.TrimSpace(params[0]), "text/csv") && !rejectedByQuality(params[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// exportUsersCSV streams every user as CSV straight from the database rows,
+// so memory stays flat however many users there are. Exports bypass the
+// cache, which only holds JSON.
+func (h *UserHandler) exportUsersCSV(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var rows *sql.Rows
+	err := h.withReader(func(db *sql.DB) error {
+		defer observeQuery(ctx, opSelectUsers, time.Now())
+		var err error
+		rows, err = h.queryPrepared(ctx, db, queryListUsers)
+		return err
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=users.csv")
+	rc := http.NewResponseController(w)
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "name", "email", "created_at", "version"})
+
+	n := 0
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(userDest(&user)...); err != nil {
+			log.Printf("CSV export aborted: %v", err)
+			return
+		}
+		out.Write([]string{
+			strconv.Itoa(user.ID),
+			csvSafe(user.Name),
+			csvSafe(user.Email),
+			user.CreatedAt.Format(time.RFC3339),
+			strconv.Itoa(user.Version),
+		})
+		if n++; n%csvFlushRows == 0 {
+			out.Flush()
+			rc.Flush()
+		}
+	}
+	out.Flush()
+	if err := rows.Err(); err != nil {
+		log.Printf("CSV export aborted: %v", err)
+	} else if err := out.Error(); err != nil {
+		log.Printf("CSV export aborted: %v", err)
+	}
+}
+
+// csvSafe keeps spreadsheets from evaluating user-supplied values as
+// formulas by prefixing those that start with a formula character.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
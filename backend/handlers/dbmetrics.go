@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"k8s-autoscale-webapp/metrics"
+)
+
+// Operation labels for db_query_duration_seconds. Keep this a fixed set so
+// label cardinality stays bounded.
+const (
+	opSelectUsers          = "select_users"
+	opSelectUsersPage      = "select_users_page"
+	opSelectUsersBatch     = "select_users_batch"
+	opSelectUser           = "select_user"
+	opSelectUserByEmail    = "select_user_by_email"
+	opSelectUserExists     = "select_user_exists"
+	opSelectUserPosts      = "select_user_posts"
+	opCountUserPosts       = "count_user_posts"
+	opSelectUserTimeseries = "select_user_timeseries"
+	opInsertUser           = "insert_user"
+	opUpdateUser           = "update_user"
+	opDeleteUser           = "delete_user"
+	opCountUsers           = "count_users"
+	opUpsertUsers          = "upsert_users"
+	opStressCountUsers     = "stress_count_users"
+)
+
+// observeQuery records the time since start under op and in the db
+// Server-Timing of the request behind ctx; call it deferred at the top of
+// the query closure.
+func observeQuery(ctx context.Context, op string, start time.Time) {
+	elapsed := time.Since(start)
+	metrics.DBQueryDuration.WithLabelValues(op).Observe(elapsed.Seconds())
+	recordTiming(ctx, timingDB, elapsed)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// RuntimeStats reports goroutine, heap and GC figures for quick checks under
+// load. ReadMemStats briefly stops the world, so keep it admin-only.
+func RuntimeStats(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := models.RuntimeStats{
+		Goroutines:    runtime.NumGoroutine(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		NumCPU:        runtime.NumCPU(),
+		HeapAlloc:     mem.HeapAlloc,
+		HeapInuse:     mem.HeapInuse,
+		HeapObjects:   mem.HeapObjects,
+		Sys:           mem.Sys,
+		NumGC:         mem.NumGC,
+		GCPauseTotal:  time.Duration(mem.PauseTotalNs).String(),
+		LastGCPause:   time.Duration(mem.PauseNs[(mem.NumGC+255)%256]).String(),
+		GCCPUFraction: mem.GCCPUFraction,
+	}
+	if mem.LastGC != 0 {
+		stats.LastGC = time.Unix(0, int64(mem.LastGC))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// CPUStats compares GOMAXPROCS with the container CPU limit. A mismatch
+// skews CPU stress runs and the utilization the HPA scales on: too many Ps
+// get the pod throttled, too few leave its quota unused.
+func CPUStats(w http.ResponseWriter, r *http.Request) {
+	stats := models.CPUStats{
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		CPULimit:   CgroupCPULimit(),
+	}
+	if want := CgroupGOMAXPROCS(); want > 0 && want != stats.GOMAXPROCS {
+		stats.Mismatch = true
+		stats.Warning = fmt.Sprintf("GOMAXPROCS is %d but the CPU limit of %g cores suggests %d", stats.GOMAXPROCS, stats.CPULimit, want)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, stats)
+}
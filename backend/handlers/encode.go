@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// safeEncode marshals v, logging any failure so callers can answer 500
+// rather than writing or caching a partial value.
+func safeEncode(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode %T: %v", v, err)
+	}
+	return data, err
+}
+
+// writeJSON writes v as JSON with status, or a 500 if v cannot be encoded.
+// Like json.Encoder it terminates the body with a newline.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := safeEncode(v)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+// cacheJSON encodes v and caches it under key. Nothing is cached when
+// encoding fails.
+func (h *UserHandler) cacheJSON(key string, v any, ttl time.Duration) ([]byte, error) {
+	data, err := safeEncode(v)
+	if err != nil {
+		return nil, err
+	}
+	h.cacheSet(key, data, ttl)
+	return data, nil
+}
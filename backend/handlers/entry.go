@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s-autoscale-webapp/metrics"
+)
+
+// revalidateTimeout bounds a background stale-while-revalidate refresh.
+const revalidateTimeout = 10 * time.Second
+
+// Cached payloads are stored as "<unix millis>\n<json>" so readers know how
+// old an entry is independently of its Redis TTL.
+
+func encodeCacheEntry(payload []byte, cachedAt time.Time) []byte {
+	entry := strconv.AppendInt(nil, cachedAt.UnixMilli(), 10)
+	entry = append(entry, '\n')
+	return append(entry, payload...)
+}
+
+// decodeCacheEntry splits an entry into its payload and write time. Entries
+// without a timestamp are returned as-is with a zero time.
+func decodeCacheEntry(entry []byte) ([]byte, time.Time) {
+	stamp, payload, ok := bytes.Cut(entry, []byte("\n"))
+	if !ok {
+		return entry, time.Time{}
+	}
+	millis, err := strconv.ParseInt(string(stamp), 10, 64)
+	if err != nil {
+		return entry, time.Time{}
+	}
+	return payload, time.UnixMilli(millis)
+}
+
+// cacheGet reads key, adding the lookup to the cache Server-Timing of the
+// request behind ctx.
+func (h *UserHandler) cacheGet(ctx context.Context, key string) ([]byte, time.Time, error) {
+	start := time.Now()
+	entry, err := h.Cache.Get(h.Ctx, key)
+	recordTiming(ctx, timingCache, time.Since(start))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	payload, cachedAt := decodeCacheEntry(entry)
+	return payload, cachedAt, nil
+}
+
+// observeCacheSize records len(payload) for the user list and per-user
+// caches; other keys are ignored.
+func observeCacheSize(key string, payload []byte) {
+	var cache string
+	switch {
+	case strings.HasPrefix(key, "users:all"):
+		cache = "user_list"
+	case strings.HasPrefix(key, "user:") && strings.Count(key, ":") == 1:
+		cache = "user"
+	default:
+		return
+	}
+	metrics.CachePayloadBytes.WithLabelValues(cache).Observe(float64(len(payload)))
+}
+
+func (h *UserHandler) cacheSet(key string, payload []byte, ttl time.Duration) {
+	if h.oom.paused() {
+		return
+	}
+	observeCacheSize(key, payload)
+	h.oom.observe(h.Cache.Set(h.Ctx, key, encodeCacheEntry(payload, time.Now()), ttl))
+}
+
+// revalidate refreshes key in the background with load once the entry is
+// older than the soft TTL, so the stale copy is served without waiting. Only
+// one refresh per key runs at a time.
+func (h *UserHandler) revalidate(key string, cachedAt time.Time, load func(ctx context.Context) error) {
+	softTTL := h.Config.CacheSoftTTL
+	if softTTL <= 0 || time.Since(cachedAt) < softTTL {
+		return
+	}
+	if _, running := h.revalidating.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer h.revalidating.Delete(key)
+
+		ctx, cancel := context.WithTimeout(h.Ctx, revalidateTimeout)
+		defer cancel()
+		if err := load(ctx); err != nil {
+			log.Printf("Background refresh of %s failed: %v", key, err)
+		}
+	}()
+}
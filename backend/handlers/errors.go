@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// JSONErrorMiddleware rewrites plain-text error responses, as written by
+// http.Error, into {"error": "...", "request_id": "..."} so clients can
+// quote the request ID. JSON error objects, such as validation errors, get
+// the request_id field added. 5xx responses are logged with the same ID. It
+// must run inside RequestIDMiddleware.
+func JSONErrorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew := &errorWriter{ResponseWriter: w, r: r}
+		next.ServeHTTP(ew, r)
+		ew.finish()
+	})
+}
+
+// errorWriter buffers text/plain and JSON bodies of responses with a 4xx or
+// 5xx status; everything else passes straight through.
+type errorWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	status      int
+	wroteHeader bool
+	buffering   bool
+	isJSON      bool
+	buf         bytes.Buffer
+}
+
+func (ew *errorWriter) WriteHeader(status int) {
+	if ew.wroteHeader {
+		return
+	}
+	ew.wroteHeader = true
+	ew.status = status
+
+	contentType := ew.Header().Get("Content-Type")
+	ew.isJSON = strings.HasPrefix(contentType, "application/json")
+	ew.buffering = status >= 400 && (ew.isJSON || strings.HasPrefix(contentType, "text/plain"))
+	if !ew.buffering {
+		ew.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (ew *errorWriter) Write(p []byte) (int, error) {
+	if !ew.wroteHeader {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if ew.buffering {
+		return ew.buf.Write(p)
+	}
+	return ew.ResponseWriter.Write(p)
+}
+
+func (ew *errorWriter) Flush() {
+	if ew.buffering {
+		return
+	}
+	if f, ok := ew.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (ew *errorWriter) finish() {
+	if !ew.buffering {
+		return
+	}
+
+	message := strings.TrimSpace(ew.buf.String())
+	requestID := RequestID(ew.r.Context())
+	if ew.status >= 500 {
+		log.Printf("Request %s %s %s failed with %d: %s", requestID, ew.r.Method, ew.r.URL.Path, ew.status, message)
+	}
+
+	ew.Header().Del("Content-Length")
+	if ew.isJSON {
+		ew.finishJSON(requestID)
+		return
+	}
+	ew.Header().Set("Content-Type", "application/json")
+	writeJSON(ew.ResponseWriter, ew.status, models.ErrorResponse{Error: message, RequestID: requestID})
+}
+
+// finishJSON adds request_id to a JSON error object that lacks one. Bodies
+// that are not objects are sent unchanged.
+func (ew *errorWriter) finishJSON(requestID string) {
+	var body map[string]json.RawMessage
+	if requestID == "" || json.Unmarshal(ew.buf.Bytes(), &body) != nil || body == nil || body["request_id"] != nil {
+		ew.ResponseWriter.WriteHeader(ew.status)
+		ew.ResponseWriter.Write(ew.buf.Bytes())
+		return
+	}
+	body["request_id"], _ = json.Marshal(requestID)
+	writeJSON(ew.ResponseWriter, ew.status, body)
+}
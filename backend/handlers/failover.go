@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// failoverResetInterval limits how often the pools are reset, so a burst of
+// failing requests during a failover triggers one reset rather than many.
+const failoverResetInterval = time.Second
+
+// FailoverMonitor evicts pooled DB connections once a query fails at the
+// connection level, as happens when Postgres fails over and the old
+// connections point at a dead or demoted server. New connections then
+// resolve to the new primary without waiting for ConnMaxLifetime or a pod
+// restart. A nil monitor does nothing.
+type FailoverMonitor struct {
+	dbs     []*sql.DB
+	maxIdle int
+
+	mu           sync.Mutex
+	lastReset    time.Time
+	recovering   bool
+	lastRecovery time.Time
+}
+
+// NewFailoverMonitor watches dbs, whose pools keep maxIdle idle connections.
+func NewFailoverMonitor(maxIdle int, dbs ...*sql.DB) *FailoverMonitor {
+	m := &FailoverMonitor{maxIdle: maxIdle}
+	for _, db := range dbs {
+		if db != nil {
+			m.dbs = append(m.dbs, db)
+		}
+	}
+	return m
+}
+
+// Observe records the outcome of a DB call: a connection-level error resets
+// the pools, and the first success afterwards marks the recovery.
+func (m *FailoverMonitor) Observe(err error) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		if m.recovering {
+			m.recovering = false
+			m.lastRecovery = time.Now()
+			log.Printf("Database recovered %s after connection reset", m.lastRecovery.Sub(m.lastReset).Round(time.Millisecond))
+		}
+		return
+	}
+	if !isConnectionError(err) || time.Since(m.lastReset) < failoverResetInterval {
+		return
+	}
+
+	log.Printf("Database connection error, resetting connection pools: %v", err)
+	for _, db := range m.dbs {
+		// Dropping the idle limit to 0 closes every idle connection;
+		// connections in use are closed when returned or when they fail.
+		db.SetMaxIdleConns(0)
+		db.SetMaxIdleConns(m.maxIdle)
+	}
+	m.lastReset = time.Now()
+	m.recovering = true
+}
+
+// LastRecovery returns when the DB last recovered from a connection reset,
+// or the zero time if it never has.
+func (m *FailoverMonitor) LastRecovery() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastRecovery
+}
+
+// isConnectionError reports whether err means the connection itself is
+// unusable rather than the query failing: broken sockets, server shutdowns
+// (57P01-57P03), connection exceptions (class 08), and writes rejected by a
+// primary that was demoted to a read-only standby (25006). Timeouts and
+// cancellations are not: under load they mean a slow DB, and resetting the
+// pools would only slow it further.
+func isConnectionError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || isQueryCanceled(err) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		code := string(pqErr.Code)
+		return strings.HasPrefix(code, "08") || code == "57P01" || code == "57P02" || code == "57P03" || code == "25006"
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && !opErr.Timeout()
+}
+
+// isQueryCanceled reports whether Postgres canceled the statement (57014),
+// as it does when statement_timeout expires.
+func isQueryCanceled(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "57014"
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// timeoutError is a net.Error that timed out, like a dial or read deadline.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad conn", driver.ErrBadConn, true},
+		{"eof", io.EOF, true},
+		{"reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, true},
+		{"connection exception", &pq.Error{Code: "08006"}, true},
+		{"read-only standby", &pq.Error{Code: "25006"}, true},
+		{"dial refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, true},
+		{"canceled", context.Canceled, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"wrapped deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), false},
+		{"statement timeout", &pq.Error{Code: "57014"}, false},
+		{"read timeout", &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, false},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := isConnectionError(tt.err); got != tt.want {
+			t.Errorf("%s: isConnectionError = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
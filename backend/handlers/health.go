@@ -3,48 +3,250 @@ package handlers
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"k8s-autoscale-webapp/cache"
 	"k8s-autoscale-webapp/models"
-
-	"github.com/go-redis/redis/v8"
 )
 
 type HealthHandler struct {
-	DB  *sql.DB
-	RDB *redis.Client
-	Ctx context.Context
+	DB    *sql.DB
+	Cache cache.Cache
+	Ctx   context.Context
+	// CacheTTL is how long a dependency check is reused, so frequent probes
+	// across many pods don't each ping the DB and Redis.
+	CacheTTL    time.Duration
+	Environment string
+	// Required names the dependencies ("db", "redis") that must be
+	// connected for the pod to be ready; the others are only reported.
+	Required map[string]bool
+	// DegradedLatency reports "degraded" when a dependency answers slower
+	// than this; 0 disables the latency check.
+	DegradedLatency time.Duration
+	// SchemaVersion is the database schema version detected at startup,
+	// 0 if unknown.
+	SchemaVersion int
+
+	// failover, if set, is reset by failed pings and reports recoveries.
+	failover *FailoverMonitor
+
+	mu        sync.Mutex
+	last      models.HealthResponse
+	checkedAt time.Time
 }
 
-func NewHealthHandler(db *sql.DB, rdb *redis.Client, ctx context.Context) *HealthHandler {
+func NewHealthHandler(db *sql.DB, c cache.Cache, ctx context.Context, cacheTTL time.Duration, environment string, required []string) *HealthHandler {
+	requiredSet := make(map[string]bool, len(required))
+	for _, dep := range required {
+		requiredSet[dep] = true
+	}
 	return &HealthHandler{
-		DB:  db,
-		RDB: rdb,
-		Ctx: ctx,
+		DB:          db,
+		Cache:       c,
+		Ctx:         ctx,
+		CacheTTL:    cacheTTL,
+		Environment: environment,
+		Required:    requiredSet,
 	}
 }
 
+// DetectFailover lets DB pings reset the pools through m and adds its last
+// recovery time to the response.
+func (h *HealthHandler) DetectFailover(m *FailoverMonitor) {
+	h.failover = m
+}
+
+// ServeHTTP always answers 200, whatever the status field says: /health
+// backs the liveness probe, and restarting pods does not fix a dependency.
+// /readyz is what fails while a required dependency is down.
 func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	dbStatus := "connected"
-	if err := h.DB.Ping(); err != nil {
-		dbStatus = "disconnected"
+	writeJSON(w, http.StatusOK, h.status())
+}
+
+// status returns the last dependency check if it is younger than CacheTTL,
+// otherwise runs a fresh one. The lock also collapses concurrent probes into
+// a single check.
+func (h *HealthHandler) status() models.HealthResponse {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.CacheTTL > 0 && time.Since(h.checkedAt) < h.CacheTTL {
+		return h.last
+	}
+
+	h.last = h.check()
+	h.checkedAt = time.Now()
+	return h.last
+}
+
+func (h *HealthHandler) check() models.HealthResponse {
+	dbDep := h.probe("db", func() error {
+		err := h.DB.Ping()
+		h.failover.Observe(err)
+		return err
+	})
+	redisDep := models.DependencyStatus{Name: "redis", Status: "disabled", Required: h.Required["redis"]}
+	if cache.Enabled(h.Cache) {
+		redisDep = h.probe("redis", func() error {
+			return h.Cache.Ping(h.Ctx)
+		})
+	}
+
+	var lastRecovery *time.Time
+	if t := h.failover.LastRecovery(); !t.IsZero() {
+		lastRecovery = &t
+	}
+
+	deps := []models.DependencyStatus{dbDep, redisDep}
+	return models.HealthResponse{
+		Status:               h.overall(deps),
+		Database:             dbDep.Status,
+		Redis:                redisDep.Status,
+		Environment:          h.Environment,
+		Timestamp:            time.Now(),
+		Dependencies:         deps,
+		LastFailoverRecovery: lastRecovery,
+		SchemaVersion:        h.SchemaVersion,
+	}
+}
+
+// probe times ping against the named dependency.
+func (h *HealthHandler) probe(name string, ping func() error) models.DependencyStatus {
+	start := time.Now()
+	err := ping()
+	dep := models.DependencyStatus{
+		Name:      name,
+		Status:    "connected",
+		Required:  h.Required[name],
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		dep.Status = "disconnected"
 	}
+	return dep
+}
 
-	redisStatus := "connected"
-	if _, err := h.RDB.Ping(h.Ctx).Result(); err != nil {
-		redisStatus = "disconnected"
+// overall is "unhealthy" when a required dependency is down, "degraded"
+// when an optional one is down or any answers slower than DegradedLatency,
+// and "healthy" otherwise.
+func (h *HealthHandler) overall(deps []models.DependencyStatus) string {
+	status := "healthy"
+	for _, dep := range deps {
+		switch {
+		case dep.Status == "disabled":
+		case dep.Status != "connected" && dep.Required:
+			return "unhealthy"
+		case dep.Status != "connected",
+			h.DegradedLatency > 0 && dep.LatencyMS > float64(h.DegradedLatency.Microseconds())/1000:
+			status = "degraded"
+		}
 	}
+	return status
+}
 
-	response := models.HealthResponse{
-		Status:    "healthy",
-		Database:  dbStatus,
-		Redis:     redisStatus,
-		Timestamp: time.Now(),
+// requiredDown lists the required dependencies that are not connected.
+func (h *HealthHandler) requiredDown() ([]models.DependencyStatus, []string) {
+	deps := h.status().Dependencies
+	var down []string
+	for _, dep := range deps {
+		if dep.Required && dep.Status != "connected" && dep.Status != "disabled" {
+			down = append(down, dep.Name)
+		}
+	}
+	return deps, down
+}
+
+// ReadinessHandler reports whether the pod should receive traffic. It is
+// flipped to not-ready at the start of graceful shutdown, and optionally
+// while the DB connection pool is saturated.
+type ReadinessHandler struct {
+	ready atomic.Bool
+
+	// health, when set, makes the pod not ready while a required
+	// dependency is down.
+	health *HealthHandler
+
+	// pool, when set, is checked on every probe: the pod is not ready while
+	// all MaxOpenConnections are in use and WaitCount grew by at least
+	// waitThreshold since the previous probe.
+	pool          *sql.DB
+	waitThreshold int64
+	mu            sync.Mutex
+	lastWaitCount int64
+}
+
+func NewReadinessHandler() *ReadinessHandler {
+	h := &ReadinessHandler{}
+	h.ready.Store(true)
+	return h
+}
+
+func (h *ReadinessHandler) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// CheckDependencies gates readiness on health's required dependencies.
+func (h *ReadinessHandler) CheckDependencies(health *HealthHandler) {
+	h.health = health
+}
+
+// CheckPool enables the pool saturation check. Pools without a
+// MaxOpenConnections limit never saturate and are ignored.
+func (h *ReadinessHandler) CheckPool(db *sql.DB, waitThreshold int) {
+	h.pool = db
+	h.waitThreshold = int64(max(waitThreshold, 1))
+	h.lastWaitCount = db.Stats().WaitCount
+}
+
+// poolStatus returns the pool stats and whether the pool is saturated.
+func (h *ReadinessHandler) poolStatus() (*models.PoolStats, bool) {
+	stats := h.pool.Stats()
+
+	h.mu.Lock()
+	waitDelta := stats.WaitCount - h.lastWaitCount
+	h.lastWaitCount = stats.WaitCount
+	h.mu.Unlock()
+
+	saturated := stats.MaxOpenConnections > 0 &&
+		stats.InUse >= stats.MaxOpenConnections &&
+		waitDelta >= h.waitThreshold
+
+	return &models.PoolStats{
+		MaxOpen:   stats.MaxOpenConnections,
+		Open:      stats.OpenConnections,
+		InUse:     stats.InUse,
+		Idle:      stats.Idle,
+		WaitCount: stats.WaitCount,
+		WaitDelta: waitDelta,
+		Saturated: saturated,
+	}, saturated
+}
+
+func (h *ReadinessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := models.ReadinessResponse{Status: "ready"}
+	ready := h.ready.Load()
+	if h.health != nil {
+		var down []string
+		response.Dependencies, down = h.health.requiredDown()
+		ready = ready && len(down) == 0
+	}
+	if h.pool != nil {
+		var saturated bool
+		response.Pool, saturated = h.poolStatus()
+		ready = ready && !saturated
+	}
+	status := http.StatusOK
+	if !ready {
+		response.Status = "not ready"
+		status = http.StatusServiceUnavailable
 	}
 
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, status, response)
 }
\ No newline at end of file
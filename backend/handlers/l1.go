@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// invalidationChannel carries comma-separated user ids whose per-pod L1
+// entries must be dropped.
+const invalidationChannel = "users:invalidate"
+
+// l1Get returns a user payload from the in-process cache.
+func (h *UserHandler) l1Get(id int) ([]byte, bool) {
+	if h.l1 == nil {
+		return nil, false
+	}
+	return h.l1.Get(id)
+}
+
+func (h *UserHandler) l1Add(id int, payload []byte) {
+	if h.l1 != nil {
+		h.l1.Add(id, payload)
+	}
+}
+
+// l1Invalidate drops ids from this pod's L1 and tells the other pods to do
+// the same.
+func (h *UserHandler) l1Invalidate(ids []int) {
+	if h.l1 == nil || len(ids) == 0 {
+		return
+	}
+
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		h.l1.Remove(id)
+		parts[i] = strconv.Itoa(id)
+	}
+	if err := h.Cache.Publish(h.Ctx, invalidationChannel, strings.Join(parts, ",")); err != nil {
+		log.Printf("Failed to publish L1 invalidation: %v", err)
+	}
+}
+
+// SubscribeInvalidations applies L1 invalidations published by other pods
+// until ctx is done. It is a no-op when the L1 cache is disabled.
+func (h *UserHandler) SubscribeInvalidations(ctx context.Context) {
+	if h.l1 == nil {
+		return
+	}
+
+	h.Cache.Subscribe(ctx, invalidationChannel, func(message string) {
+		for _, part := range strings.Split(message, ",") {
+			if id, err := strconv.Atoi(part); err == nil {
+				h.l1.Remove(id)
+			}
+		}
+	})
+}
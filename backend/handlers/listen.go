@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// usersChangedChannel is the Postgres NOTIFY channel fed by the users
+// trigger created in initDB.
+const usersChangedChannel = "users_changed"
+
+// listenerPingInterval checks an idle listener connection is still alive.
+const listenerPingInterval = 90 * time.Second
+
+// userChange is the trigger's notification payload; Emails holds the row's
+// email and, for updates, the previous one. Origin is the application_name
+// of the connection that made the change.
+type userChange struct {
+	ID     int      `json:"id"`
+	Emails []string `json:"emails"`
+	Origin string   `json:"origin"`
+}
+
+// ListenForChanges invalidates cached users whenever the users table
+// changes, including through SQL run outside the app, until ctx is done.
+// pq.Listener reconnects on its own; since notifications sent while it was
+// disconnected are lost, every cached user list is dropped on reconnect.
+// Changes made through connections named origin, this pod's own, are
+// skipped since the write path already invalidated them.
+func (h *UserHandler) ListenForChanges(ctx context.Context, connStr, origin string) {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Users change listener: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(usersChangedChannel); err != nil {
+		log.Printf("Failed to listen on %s: %v", usersChangedChannel, err)
+		return
+	}
+
+	ping := time.NewTicker(listenerPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			if n == nil {
+				log.Printf("Users change listener reconnected, invalidating user lists")
+				h.invalidateUsers()
+				continue
+			}
+			h.applyUserChange(n.Extra, origin)
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
+
+func (h *UserHandler) applyUserChange(payload, origin string) {
+	var change userChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		log.Printf("Ignoring malformed %s notification %q: %v", usersChangedChannel, payload, err)
+		return
+	}
+	if origin != "" && change.Origin == origin {
+		return
+	}
+	h.invalidateUsers(change.ID)
+	h.invalidateUserEmails(change.Emails...)
+}
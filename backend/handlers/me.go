@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s-autoscale-webapp/auth"
+	"k8s-autoscale-webapp/models"
+)
+
+// GetMe returns the user identified by the token subject, which is either a
+// numeric user id or an email address. It must be wrapped with auth.Require.
+// Its cache entries are scoped to the subject, never shared between callers.
+func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	subject, ok := auth.Subject(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var q cachedQuery
+	if id, err := strconv.Atoi(subject); err == nil {
+		q = h.userQuery(id)
+	} else if strings.Contains(subject, "@") {
+		q = h.userEmailQuery(subject)
+	} else {
+		http.Error(w, "Unauthorized: token subject is not a user id or email", http.StatusUnauthorized)
+		return
+	}
+	q.namespace, q.subject = "user:me", subject
+
+	userJSON, _, err := h.fetch(r.Context(), q, true)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			writeDBError(w, err)
+		}
+		return
+	}
+
+	h.writeUser(w, r, userJSON)
+}
+
+// userEmailQuery reads one user by email, returning sql.ErrNoRows when none
+// matches.
+func (h *UserHandler) userEmailQuery(email string) cachedQuery {
+	return cachedQuery{
+		namespace: "user:email",
+		sql:       queryGetUserByEmail,
+		args:      []any{email},
+		run: func(ctx context.Context) (any, error) {
+			var user models.User
+			err := h.withReader(func(db *sql.DB) error {
+				defer observeQuery(ctx, opSelectUserByEmail, time.Now())
+				return db.QueryRowContext(ctx, queryGetUserByEmail, email).Scan(userDest(&user)...)
+			})
+			return user, err
+		},
+	}
+}
@@ -1,12 +1,22 @@
 package handlers
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s-autoscale-webapp/metrics"
+)
 
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Cache-Control, Prefer, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "Location, Preference-Applied, X-Cache, X-Cache-TTL, X-Request-ID, X-Served-By, X-Total-Count")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -15,4 +25,185 @@ func CORSMiddleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(w, r)
 	})
-}
\ No newline at end of file
+}
+
+// JSONOnlyMiddleware rejects requests whose Accept header explicitly excludes
+// application/json. A missing header, */* and application/* are treated as
+// JSON so browsers and curl keep working. Endpoints in eventStreamPaths also
+// accept text/event-stream, and those in csvPaths text/csv.
+func JSONOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		streaming := eventStreamPaths[r.URL.Path] && wantsEventStream(r)
+		exporting := csvPaths[r.URL.Path] && wantsCSV(r)
+		if r.Method != "OPTIONS" && !streaming && !exporting && !acceptsJSON(r.Header.Get("Accept")) {
+			http.Error(w, "Not Acceptable: this endpoint only produces application/json", http.StatusNotAcceptable)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func acceptsJSON(accept string) bool {
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if rejectedByQuality(params[1:]) {
+			continue
+		}
+
+		switch {
+		case mediaType == "*/*", mediaType == "application/*", mediaType == "application/json":
+			return true
+		case strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"):
+			return true
+		}
+	}
+	return false
+}
+
+// rejectedByQuality reports whether a media range carries q=0, which means
+// the client explicitly refuses it.
+func rejectedByQuality(params []string) bool {
+	for _, param := range params {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || strings.TrimSpace(key) != "q" {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return err == nil && q == 0
+	}
+	return false
+}
+
+// TrailingSlashMiddleware canonicalizes request paths by stripping trailing
+// slashes before routing, so /api/users/ and /api/users reach the same
+// handler. In "redirect" mode clients receive a 308 to the canonical path;
+// otherwise the path is rewritten in place.
+func TrailingSlashMiddleware(mode string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.URL.Path) <= 1 || !strings.HasSuffix(r.URL.Path, "/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			canonical := strings.TrimRight(r.URL.Path, "/")
+			if canonical == "" {
+				canonical = "/"
+			}
+
+			if mode == "redirect" {
+				target := canonical
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusPermanentRedirect)
+				return
+			}
+
+			r2 := r.Clone(r.Context())
+			r2.URL.Path = canonical
+			r2.URL.RawPath = ""
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
+
+// BodyLimitMiddleware caps request bodies at maxBytes. Reading past the cap
+// fails with *http.MaxBytesError, which handlers answer with 413. A limit of
+// 0 disables it.
+func BodyLimitMiddleware(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if maxBytes <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// URLLengthMiddleware rejects request URIs longer than maxURL with 414 and
+// any single query value longer than maxParam with 400. A limit of 0 disables
+// that check.
+func URLLengthMiddleware(maxURL, maxParam int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxURL > 0 && len(r.RequestURI) > maxURL {
+				http.Error(w, "URI Too Long", http.StatusRequestURITooLong)
+				return
+			}
+
+			if maxParam > 0 {
+				for name, values := range r.URL.Query() {
+					for _, value := range values {
+						if len(value) > maxParam {
+							http.Error(w, "Query parameter too long: "+name, http.StatusBadRequest)
+							return
+						}
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequestTimeoutMiddleware derives a context deadline from the
+// X-Request-Timeout header (milliseconds), capped at max. Requests without
+// the header run without a deadline.
+func RequestTimeoutMiddleware(max time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("X-Request-Timeout")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ms, err := strconv.Atoi(header)
+			if err != nil || ms <= 0 {
+				http.Error(w, "Invalid X-Request-Timeout: expected positive milliseconds", http.StatusBadRequest)
+				return
+			}
+
+			timeout := time.Duration(ms) * time.Millisecond
+			if max > 0 && timeout > max {
+				timeout = max
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// inFlight counts requests currently being served.
+var inFlight atomic.Int64
+
+// InFlightMiddleware tracks requests being served, in the
+// http_in_flight_requests gauge and for InFlightRequests.
+func InFlightMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		metrics.InFlightRequests.Inc()
+		defer func() {
+			inFlight.Add(-1)
+			metrics.InFlightRequests.Dec()
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlightRequests returns the number of requests being served.
+func InFlightRequests() int64 {
+	return inFlight.Load()
+}
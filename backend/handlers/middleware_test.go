@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// routeMux mirrors the routes registered in main and echoes the matched
+// pattern so tests can assert which handler a path reached.
+func routeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, pattern := range []string{
+		"GET /health",
+		"GET /api/health",
+		"GET /healthz",
+		"GET /readyz",
+		"GET /api/users",
+		"POST /api/users",
+		"GET /api/users/{id}",
+		"GET /api/users/stats/timeseries",
+		"GET /api/stress",
+	} {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Pattern))
+		})
+	}
+	return mux
+}
+
+// TestProbesAnswerHEAD checks that the GET probe routes also serve HEAD,
+// which some load balancers use, with headers and no body.
+func TestProbesAnswerHEAD(t *testing.T) {
+	mux := http.NewServeMux()
+	for _, pattern := range []string{"GET /health", "GET /healthz", "GET /readyz"} {
+		mux.Handle(pattern, NewReadinessHandler())
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, path := range []string{"/health", "/healthz", "/readyz"} {
+		resp, err := http.Head(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("HEAD %s: status = %d, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+		if got := resp.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("HEAD %s: Content-Type = %q", path, got)
+		}
+		if len(body) != 0 {
+			t.Errorf("HEAD %s: body = %q, want none", path, body)
+		}
+	}
+}
+
+var trailingSlashCases = []struct {
+	method  string
+	path    string
+	pattern string
+}{
+	{"GET", "/health", "GET /health"},
+	{"GET", "/api/health", "GET /api/health"},
+	{"GET", "/healthz", "GET /healthz"},
+	{"GET", "/readyz", "GET /readyz"},
+	{"GET", "/api/users", "GET /api/users"},
+	{"POST", "/api/users", "POST /api/users"},
+	{"GET", "/api/users/42", "GET /api/users/{id}"},
+	{"GET", "/api/users/stats/timeseries", "GET /api/users/stats/timeseries"},
+	{"GET", "/api/stress", "GET /api/stress"},
+}
+
+func TestTrailingSlashMiddlewareRewrite(t *testing.T) {
+	handler := TrailingSlashMiddleware("rewrite")(routeMux())
+
+	for _, tc := range trailingSlashCases {
+		for _, path := range []string{tc.path, tc.path + "/"} {
+			req := httptest.NewRequest(tc.method, path, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("%s %s: status = %d, want %d", tc.method, path, rec.Code, http.StatusOK)
+				continue
+			}
+			if got := rec.Body.String(); got != tc.pattern {
+				t.Errorf("%s %s: matched %q, want %q", tc.method, path, got, tc.pattern)
+			}
+		}
+	}
+}
+
+func TestTrailingSlashMiddlewareRedirect(t *testing.T) {
+	handler := TrailingSlashMiddleware("redirect")(routeMux())
+
+	for _, tc := range trailingSlashCases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Body.String() != tc.pattern {
+			t.Errorf("%s %s: got %d %q, want 200 %q", tc.method, tc.path, rec.Code, rec.Body.String(), tc.pattern)
+		}
+
+		req = httptest.NewRequest(tc.method, tc.path+"/?limit=5", nil)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s %s/: status = %d, want %d", tc.method, tc.path, rec.Code, http.StatusPermanentRedirect)
+			continue
+		}
+		if got, want := rec.Header().Get("Location"), tc.path+"?limit=5"; got != want {
+			t.Errorf("%s %s/: Location = %q, want %q", tc.method, tc.path, got, want)
+		}
+	}
+}
+
+func TestTrailingSlashMiddlewareMultipleSlashes(t *testing.T) {
+	handler := TrailingSlashMiddleware("rewrite")(routeMux())
+
+	req := httptest.NewRequest("GET", "/api/users/7//", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "GET /api/users/{id}" {
+		t.Errorf("got %d %q, want 200 %q", rec.Code, rec.Body.String(), "GET /api/users/{id}")
+	}
+}
+
+func TestJSONErrorMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		status      int
+		body        string
+		want        string
+	}{
+		{"plain error", "", http.StatusNotFound, "", `{"error":"User not found","request_id":"req-1"}`},
+		{"json error object", "application/json", http.StatusUnprocessableEntity, `{"errors":[{"field":"email"}]}`, `{"errors":[{"field":"email"}],"request_id":"req-1"}`},
+		{"json error with id", "application/json", http.StatusBadRequest, `{"error":"x","request_id":"other"}`, `{"error":"x","request_id":"other"}`},
+		{"json error array", "application/json", http.StatusBadRequest, `["x"]`, `["x"]`},
+		{"json success", "application/json", http.StatusOK, `{"id":1}`, `{"id":1}`},
+	}
+	for _, tt := range tests {
+		handler := RequestIDMiddleware(JSONErrorMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tt.contentType == "" {
+				http.Error(w, "User not found", tt.status)
+				return
+			}
+			w.Header().Set("Content-Type", tt.contentType)
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		})))
+
+		req := httptest.NewRequest("GET", "/api/users/1", nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+			t.Errorf("%s: body = %s, want %s", tt.name, got, tt.want)
+		}
+		if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+			t.Errorf("%s: Content-Type = %q", tt.name, got)
+		}
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	a, b := newRequestID(), newRequestID()
+	if a == b || !validRequestID(a) || len(a) != 16 {
+		t.Errorf("newRequestID = %q, %q", a, b)
+	}
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	handler := BodyLimitMiddleware(32)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v map[string]string
+		if err := decodeJSON(r, &v); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"name":"Ada"}`, http.StatusNoContent},
+		{`{"name":"` + strings.Repeat("a", 64) + `"}`, http.StatusRequestEntityTooLarge},
+		{`{"name":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/users", strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("body of %d bytes: status = %d, want %d", len(tt.body), rec.Code, tt.want)
+		}
+	}
+}
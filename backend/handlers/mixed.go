@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// maxMixedDBQueries caps the db_queries dimension of a mixed stress run.
+const maxMixedDBQueries = 10000
+
+// Mixed serves POST /api/stress/mixed, loading CPU, memory and the database
+// at the same time. Each dimension is bounded on its own and runs to
+// completion even if another fails; the memory allocation is held until the
+// CPU and DB work finish. The allocation draws from the budget shared with
+// the memory stress mode.
+func (h *StressHandler) Mixed(w http.ResponseWriter, r *http.Request) {
+	var req models.MixedStressRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	switch {
+	case req.CPUIterations < 0 || req.CPUIterations > maxStressIterations:
+		http.Error(w, fmt.Sprintf("Invalid cpu_iterations: must be between 0 and %d", maxStressIterations), http.StatusBadRequest)
+		return
+	case req.MemoryMB < 0 || req.MemoryMB > h.maxMemoryMB:
+		http.Error(w, fmt.Sprintf("Invalid memory_mb: must be between 0 and the safe ceiling of %d", h.maxMemoryMB), http.StatusBadRequest)
+		return
+	case req.DBQueries < 0 || req.DBQueries > maxMixedDBQueries:
+		http.Error(w, fmt.Sprintf("Invalid db_queries: must be between 0 and %d", maxMixedDBQueries), http.StatusBadRequest)
+		return
+	case req.CPUIterations == 0 && req.MemoryMB == 0 && req.DBQueries == 0:
+		http.Error(w, "At least one of cpu_iterations, memory_mb and db_queries must be positive", http.StatusBadRequest)
+		return
+	}
+	if req.MemoryMB > 0 {
+		if !h.reserveMemory(w, req.MemoryMB) {
+			return
+		}
+		defer h.releaseMemory(req.MemoryMB)
+	}
+
+	ctx := r.Context()
+	response := models.MixedStressResponse{Message: "Mixed stress test completed"}
+
+	var work, all sync.WaitGroup
+	workDone := make(chan struct{})
+	if req.CPUIterations > 0 {
+		response.CPU = &models.StressDimension{Requested: req.CPUIterations}
+		work.Add(1)
+		go func() {
+			defer work.Done()
+			runDimension(response.CPU, func() (int, error) {
+				return burnCPUContext(ctx, req.CPUIterations)
+			})
+		}()
+	}
+	if req.DBQueries > 0 {
+		response.DB = &models.StressDimension{Requested: req.DBQueries}
+		work.Add(1)
+		go func() {
+			defer work.Done()
+			runDimension(response.DB, func() (int, error) {
+				completed, timedOut, err := h.queryDB(ctx, req.DBQueries)
+				response.DB.TimedOut = timedOut
+				return completed, err
+			})
+		}()
+	}
+	if req.MemoryMB > 0 {
+		response.Memory = &models.StressDimension{Requested: req.MemoryMB}
+		all.Add(1)
+		go func() {
+			defer all.Done()
+			runDimension(response.Memory, func() (int, error) {
+				return holdMemory(ctx, req.MemoryMB, workDone)
+			})
+		}()
+	}
+
+	work.Wait()
+	close(workDone)
+	all.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, response)
+}
+
+// runDimension runs fn, recording its result, duration and any error
+// (including a panic) in dim.
+func runDimension(dim *models.StressDimension, fn func() (int, error)) {
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			dim.Error = fmt.Sprint("panic: ", p)
+		}
+		dim.Duration = time.Since(start).String()
+	}()
+
+	result, err := fn()
+	dim.Result = result
+	if err != nil {
+		dim.Error = err.Error()
+	}
+}
+
+// queryDB runs n small queries against the users table one after another
+// and returns how many succeeded and how many hit DBQueryTimeout. A timed
+// out query is skipped; any other error ends the run.
+func (h *StressHandler) queryDB(ctx context.Context, n int) (completed, timedOut int, err error) {
+	if h.db == nil {
+		return 0, 0, fmt.Errorf("database not configured")
+	}
+	for i := 0; i < n; i++ {
+		err := h.countUsers(ctx)
+		switch {
+		case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+			timedOut++
+		case err != nil:
+			return completed, timedOut, err
+		default:
+			completed++
+		}
+	}
+	return completed, timedOut, nil
+}
+
+// countUsers runs one stress query, bounded by DBQueryTimeout so stress
+// runs can't hold pooled connections that regular traffic needs.
+func (h *StressHandler) countUsers(ctx context.Context) error {
+	if h.DBQueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.DBQueryTimeout)
+		defer cancel()
+	}
+	defer observeQuery(ctx, opStressCountUsers, time.Now())
+	var count int
+	return h.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+}
+
+// holdMemory allocates and touches mb megabytes, keeping them until release
+// is closed or ctx is done.
+func holdMemory(ctx context.Context, mb int, release <-chan struct{}) (int, error) {
+	buf := make([]byte, mb<<20)
+	for i := 0; i < len(buf); i += 4096 {
+		buf[i] = 1
+	}
+
+	select {
+	case <-release:
+	case <-ctx.Done():
+	}
+	runtime.KeepAlive(buf)
+	return mb, nil
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// JSONNamingMiddleware rewrites snake_case object keys in JSON responses to
+// camelCase. Handlers and the cache always work with snake_case, so the
+// policy is applied once at the edge and cached payloads never mix formats.
+func JSONNamingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &camelCaseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		cw.finish()
+	})
+}
+
+// camelCaseWriter buffers JSON bodies so their keys can be rewritten; any
+// other content type is passed straight through.
+type camelCaseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	passthrough bool
+	buf         bytes.Buffer
+}
+
+func (cw *camelCaseWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+
+	contentType := cw.Header().Get("Content-Type")
+	cw.passthrough = !strings.HasPrefix(contentType, "application/json")
+	if cw.passthrough {
+		cw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (cw *camelCaseWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.passthrough {
+		return cw.ResponseWriter.Write(p)
+	}
+	return cw.buf.Write(p)
+}
+
+func (cw *camelCaseWriter) Flush() {
+	if !cw.passthrough {
+		return
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *camelCaseWriter) finish() {
+	if !cw.wroteHeader || cw.passthrough {
+		return
+	}
+
+	body := cw.buf.Bytes()
+	if converted, err := camelCaseKeys(body); err == nil {
+		body = converted
+	}
+
+	cw.Header().Del("Content-Length")
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.ResponseWriter.Write(body)
+}
+
+// camelCaseKeys re-emits a JSON document token by token, converting object
+// keys while preserving key order and values.
+func camelCaseKeys(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var out bytes.Buffer
+	// Each stack entry tracks an open container: whether it is an object,
+	// whether the next token is a key, and whether a separator is needed.
+	type frame struct {
+		object    bool
+		expectKey bool
+		count     int
+	}
+	var stack []frame
+
+	writeSeparator := func() {
+		if len(stack) == 0 {
+			return
+		}
+		top := &stack[len(stack)-1]
+		if top.object && !top.expectKey {
+			out.WriteByte(':')
+			top.expectKey = true
+			return
+		}
+		if top.count > 0 {
+			out.WriteByte(',')
+		}
+		top.count++
+		if top.object {
+			top.expectKey = false
+		}
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		switch v := tok.(type) {
+		case json.Delim:
+			switch v {
+			case '{', '[':
+				writeSeparator()
+				out.WriteByte(byte(v))
+				stack = append(stack, frame{object: v == '{', expectKey: v == '{'})
+			case '}', ']':
+				stack = stack[:len(stack)-1]
+				out.WriteByte(byte(v))
+			}
+		case string:
+			isKey := len(stack) > 0 && stack[len(stack)-1].object && stack[len(stack)-1].expectKey
+			writeSeparator()
+			if isKey {
+				v = snakeToCamel(v)
+			}
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(encoded)
+		default:
+			writeSeparator()
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(encoded)
+		}
+
+		if len(stack) == 0 {
+			out.WriteByte('\n')
+		}
+	}
+	return out.Bytes(), nil
+}
+
+func snakeToCamel(s string) string {
+	if !strings.Contains(s, "_") {
+		return s
+	}
+
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s-autoscale-webapp/metrics"
+)
+
+// Cache writes pause for oomCooldown after Redis reports it is out of
+// memory, doubling on each consecutive rejection up to oomMaxCooldown so a
+// persistently full Redis is effectively treated as a read-only cache.
+const (
+	oomCooldown    = 10 * time.Second
+	oomMaxCooldown = 5 * time.Minute
+)
+
+// cacheOOM pauses cache writes while Redis rejects them with OOM, which
+// happens under maxmemory with the noeviction policy. Reads are unaffected.
+type cacheOOM struct {
+	mu       sync.Mutex
+	until    time.Time
+	cooldown time.Duration
+}
+
+func (o *cacheOOM) paused() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return time.Now().Before(o.until)
+}
+
+// observe records the outcome of a cache write.
+func (o *cacheOOM) observe(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !isRedisOOM(err) {
+		if err == nil {
+			o.cooldown = 0
+		}
+		return
+	}
+
+	metrics.CacheOOMErrors.Inc()
+	o.cooldown = min(max(2*o.cooldown, oomCooldown), oomMaxCooldown)
+	o.until = time.Now().Add(o.cooldown)
+	log.Printf("Redis is out of memory, skipping cache writes for %s: %v", o.cooldown, err)
+}
+
+func isRedisOOM(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "OOM ")
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+
+	// totalCountHeader carries the total row count of a limit=0 listing.
+	totalCountHeader = "X-Total-Count"
+
+	// cursorTimeLayout matches the microsecond precision of Postgres
+	// timestamps and carries no zone, since created_at is a TIMESTAMP.
+	cursorTimeLayout = "2006-01-02T15:04:05.999999"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// userCursor holds the sort keys of the last row on a page. Users are listed
+// by created_at DESC, id DESC, so the pair uniquely positions a row even when
+// several users share a timestamp.
+type userCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+func (c userCursor) encode() string {
+	raw := c.CreatedAt.Format(cursorTimeLayout) + "|" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeUserCursor(s string) (userCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return userCursor{}, errInvalidCursor
+	}
+
+	ts, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return userCursor{}, errInvalidCursor
+	}
+
+	createdAt, err := time.Parse(cursorTimeLayout, ts)
+	if err != nil {
+		return userCursor{}, errInvalidCursor
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return userCursor{}, errInvalidCursor
+	}
+
+	return userCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// pageLimit resolves a request's page size: ?limit= when present, else a
+// Prefer: page-size=N header (capped at maxPageLimit and echoed in
+// Preference-Applied), else defaultPageLimit. A limit of 0 asks for no rows,
+// only the total in X-Total-Count.
+func pageLimit(w http.ResponseWriter, r *http.Request) (int, error) {
+	if query := r.URL.Query(); query.Has("limit") {
+		return parsePageLimit(query.Get("limit"))
+	}
+	size, ok := preferPageSize(r)
+	if !ok {
+		return defaultPageLimit, nil
+	}
+	size = min(size, maxPageLimit)
+	applyPreference(w, "page-size="+strconv.Itoa(size))
+	return size, nil
+}
+
+func parsePageLimit(s string) (int, error) {
+	if s == "" {
+		return defaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 0 {
+		return 0, errors.New("invalid limit")
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return limit, nil
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// postsCacheTTL is kept short because posts are written outside this
+// service, so there is no write path to invalidate the joined result.
+const postsCacheTTL = time.Minute
+
+const (
+	queryUserPosts = `SELECT u.id, u.name, u.email, u.created_at, u.version, p.id, p.title, p.body, p.created_at
+		FROM users u
+		JOIN posts p ON p.user_id = u.id
+		WHERE u.id = $1
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3`
+	// queryCountUserPosts returns no row when the user does not exist.
+	queryCountUserPosts = `SELECT u.id, u.name, u.email, u.created_at, u.version, COUNT(p.id)
+		FROM users u
+		LEFT JOIN posts p ON p.user_id = u.id
+		WHERE u.id = $1
+		GROUP BY u.id`
+)
+
+// GetUserPosts pages through a user's posts, newest first. An offset past
+// the last post yields an empty page, and limit=0 only reports the total in
+// X-Total-Count.
+func (h *UserHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Prefer")
+
+	id, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := pageLimit(w, r)
+	if err != nil {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	offset := 0
+	if s := query.Get("offset"); s != "" {
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if limit == 0 {
+		h.countUserPosts(w, r, id, offset)
+		return
+	}
+
+	cacheKey := fmt.Sprintf("user:%d:posts:%d:%d", id, limit, offset)
+	cachedPosts, _, err := h.cacheGet(r.Context(), cacheKey)
+	if err == nil {
+		w.Write(cachedPosts)
+		return
+	}
+
+	result := models.UserPosts{Posts: []models.Post{}, Limit: limit, Offset: offset}
+	err = h.withReader(func(db *sql.DB) error {
+		defer observeQuery(r.Context(), opSelectUserPosts, time.Now())
+		rows, err := db.QueryContext(r.Context(), queryUserPosts, id, limit, offset)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		result.Posts = result.Posts[:0]
+		for rows.Next() {
+			var post models.Post
+			dest := append(userDest(&result.User), &post.ID, &post.Title, &post.Body, &post.CreatedAt)
+			err := rows.Scan(dest...)
+			if err != nil {
+				return err
+			}
+			post.UserID = result.User.ID
+			result.Posts = append(result.Posts, post)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// No joined rows means either no posts on this page or no such user.
+		if len(result.Posts) == 0 {
+			return h.queryRowPrepared(r.Context(), db, queryGetUser, []any{id}, userDest(&result.User)...)
+		}
+		return nil
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			writeDBError(w, err)
+		}
+		return
+	}
+
+	postsJSON, err := h.cacheJSON(cacheKey, result, postsCacheTTL)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(postsJSON)
+}
+
+// countUserPosts answers limit=0 with no posts and the user's post count in
+// X-Total-Count. It is not cached, so the count is always current.
+func (h *UserHandler) countUserPosts(w http.ResponseWriter, r *http.Request, id, offset int) {
+	result := models.UserPosts{Posts: []models.Post{}, Offset: offset}
+	var total int
+	err := h.withReader(func(db *sql.DB) error {
+		defer observeQuery(r.Context(), opCountUserPosts, time.Now())
+		return db.QueryRowContext(r.Context(), queryCountUserPosts, id).Scan(append(userDest(&result.User), &total)...)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			writeDBError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set(totalCountHeader, strconv.Itoa(total))
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// userFields whitelists the columns a client may project with ?fields=, in
+// the order they are selected and emitted.
+var userFields = []string{"id", "name", "email", "created_at", "version"}
+
+// parseUserFields validates a ?fields= value and returns the requested
+// fields in canonical order, so equivalent requests share a cache key.
+func parseUserFields(s string) ([]string, error) {
+	requested := make(map[string]bool)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if userFieldDest(&models.User{}, field) == nil {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		requested[field] = true
+	}
+	if len(requested) == 0 {
+		return nil, fmt.Errorf("no fields requested")
+	}
+
+	var fields []string
+	for _, field := range userFields {
+		if requested[field] {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+func userFieldDest(u *models.User, field string) any {
+	switch field {
+	case "id":
+		return &u.ID
+	case "name":
+		return &u.Name
+	case "email":
+		return &u.Email
+	case "created_at":
+		return &u.CreatedAt
+	case "version":
+		return &u.Version
+	}
+	return nil
+}
+
+// projectedUser marshals only the selected fields of a user, preserving the
+// canonical field order.
+type projectedUser struct {
+	user   models.User
+	fields []string
+}
+
+func (p projectedUser) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range p.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(userFieldDest(&p.user, field))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%q:", field)
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func projectUsers(users []models.User, fields []string) []projectedUser {
+	projected := make([]projectedUser, len(users))
+	for i, user := range users {
+		projected[i] = projectedUser{user: user, fields: fields}
+	}
+	return projected
+}
+
+// getUsersProjected lists users selecting only the requested columns. Each
+// field set is cached under its own key.
+func (h *UserHandler) getUsersProjected(w http.ResponseWriter, r *http.Request, fields []string) {
+	q := h.userProjectionQuery(fields)
+	usersJSON, hit, err := h.fetch(r.Context(), q, true)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	h.setCacheHeaders(w, q.key(), hit)
+	h.writeUsers(w, r, usersJSON)
+}
+
+func (h *UserHandler) userProjectionQuery(fields []string) cachedQuery {
+	query := "SELECT " + strings.Join(fields, ", ") + " FROM users ORDER BY created_at DESC"
+	return cachedQuery{
+		namespace: "users:all",
+		sql:       query,
+		list:      true,
+		run: func(ctx context.Context) (any, error) {
+			var users []models.User
+			err := h.withReader(func(db *sql.DB) error {
+				defer observeQuery(ctx, opSelectUsers, time.Now())
+				rows, err := db.QueryContext(ctx, query)
+				if err != nil {
+					return err
+				}
+				defer rows.Close()
+
+				users = users[:0]
+				for rows.Next() {
+					var user models.User
+					dest := make([]any, len(fields))
+					for i, field := range fields {
+						dest[i] = userFieldDest(&user, field)
+					}
+					if err := rows.Scan(dest...); err != nil {
+						return err
+					}
+					users = append(users, user)
+				}
+				return rows.Err()
+			})
+			return projectUsers(users, fields), err
+		},
+	}
+}
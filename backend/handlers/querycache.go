@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// cachedQuery is a cacheable read. Its cache key is derived from the
+// statement and its arguments, so call sites running the same query share an
+// entry and a query that gains a parameter cannot collide with its old key.
+type cachedQuery struct {
+	// namespace prefixes the key, keeping keys readable and letting
+	// observeCacheSize classify them.
+	namespace string
+	sql       string
+	args      []any
+	// run executes the query and returns the value to cache as JSON.
+	run func(ctx context.Context) (any, error)
+	// list registers the entry in userListsKey so user writes drop it.
+	list bool
+	// subject scopes the entry to one authenticated caller, for responses
+	// that depend on who asks; shared entries leave it empty. Scoped entries
+	// are registered in userSubjectsKey so user writes drop them.
+	subject string
+	// missing, if set, runs when a background refresh finds no row.
+	missing func()
+}
+
+// queryKey derives a cache key from a statement and its arguments:
+// namespace followed by a hash of both.
+func queryKey(namespace, query string, args ...any) string {
+	hash := sha256.New()
+	hash.Write([]byte(query))
+	for _, arg := range args {
+		fmt.Fprintf(hash, "\x00%T:%v", arg, arg)
+	}
+	return namespace + ":" + hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+func (q cachedQuery) key() string {
+	if q.subject != "" {
+		return queryKey(q.namespace, q.sql, append([]any{q.subject}, q.args...)...)
+	}
+	return queryKey(q.namespace, q.sql, q.args...)
+}
+
+// load runs q and caches its encoded result.
+func (h *UserHandler) load(ctx context.Context, q cachedQuery) ([]byte, error) {
+	v, err := q.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var index string
+	switch {
+	case q.list:
+		index = userListsKey
+	case q.subject != "":
+		index = userSubjectsKey
+	default:
+		return h.cacheJSON(q.key(), v, h.cacheTTL())
+	}
+
+	payload, err := safeEncode(v)
+	if err != nil {
+		return nil, err
+	}
+	h.cacheIndexed(index, q.key(), payload)
+	return payload, nil
+}
+
+// fetch returns q's cached result, or runs q and caches the result. hit
+// reports a cached result; stale ones are refreshed in the background. With
+// useCache false the cached copy is skipped but still refreshed.
+func (h *UserHandler) fetch(ctx context.Context, q cachedQuery, useCache bool) ([]byte, bool, error) {
+	key := q.key()
+	if useCache {
+		if payload, cachedAt, err := h.cacheGet(ctx, key); err == nil {
+			h.revalidate(key, cachedAt, func(ctx context.Context) error {
+				_, err := h.load(ctx, q)
+				if errors.Is(err, sql.ErrNoRows) && q.missing != nil {
+					q.missing()
+					return nil
+				}
+				return err
+			})
+			return payload, true, nil
+		}
+	}
+
+	payload, err := h.load(ctx, q)
+	return payload, false, err
+}
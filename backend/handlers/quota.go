@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// userCountTTL is how long the user count behind MAX_USERS is reused.
+const userCountTTL = 5 * time.Second
+
+// userCount caches the number of users for the quota check. It is a soft
+// cap: concurrent creates can overshoot it by the creates in flight.
+type userCount struct {
+	mu         sync.Mutex
+	count      int
+	fetched    time.Time
+	refreshing bool
+}
+
+// current returns the cached count, re-reading it once it is older than
+// userCountTTL. The query runs outside the lock, and while one request
+// refreshes, the others use the previous count.
+func (c *userCount) current(ctx context.Context, read func(context.Context) (int, error)) (int, error) {
+	c.mu.Lock()
+	if c.refreshing || time.Since(c.fetched) < userCountTTL {
+		count := c.count
+		c.mu.Unlock()
+		return count, nil
+	}
+	c.refreshing = true
+	c.mu.Unlock()
+
+	count, err := read(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshing = false
+	if err != nil {
+		return 0, err
+	}
+	c.count, c.fetched = count, time.Now()
+	return count, nil
+}
+
+// quotaExceeded reports whether adding users would take the user count past
+// Config.MaxUsers, answering 429 if so. Without a quota, or if the count
+// cannot be read, it lets the request through.
+func (h *UserHandler) quotaExceeded(ctx context.Context, w http.ResponseWriter, adding int) bool {
+	if h.Config.MaxUsers <= 0 {
+		return false
+	}
+
+	count, err := h.users.current(ctx, h.Users.Count)
+	if err != nil {
+		log.Printf("User quota check skipped: %v", err)
+		return false
+	}
+	if count+adding <= h.Config.MaxUsers {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusTooManyRequests, models.ErrorResponse{Error: "user quota exceeded"})
+	return true
+}
+
+// usersAdded keeps the cached count current between refreshes.
+func (h *UserHandler) usersAdded(n int) {
+	h.users.mu.Lock()
+	h.users.count += n
+	h.users.mu.Unlock()
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// probePaths are never rate limited so kubelet probes keep working under load.
+var probePaths = map[string]bool{
+	"/health":     true,
+	"/api/health": true,
+	"/healthz":    true,
+	"/readyz":     true,
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a per-client-IP token bucket refilled at rate tokens per
+// second up to burst. A rate of 0 lets every request through.
+type RateLimiter struct {
+	mu    sync.Mutex
+	rate  float64
+	burst int
+	// configuredBurst is the requested burst; 0 derives it from rate.
+	configuredBurst int
+	buckets         map[string]*tokenBucket
+	lastSweep       time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	l := &RateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+	l.SetLimits(rate, burst)
+	return l
+}
+
+// SetLimits changes the refill rate and burst at runtime. A burst below 1 is
+// derived from the rate.
+func (l *RateLimiter) SetLimits(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = rate
+	l.configuredBurst = burst
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	l.burst = burst
+}
+
+// SetRate changes the refill rate, keeping the configured burst.
+func (l *RateLimiter) SetRate(rate float64) {
+	l.mu.Lock()
+	burst := l.configuredBurst
+	l.mu.Unlock()
+	l.SetLimits(rate, burst)
+}
+
+// SetBurst changes the burst, keeping the refill rate.
+func (l *RateLimiter) SetBurst(burst int) {
+	l.mu.Lock()
+	rate := l.rate
+	l.mu.Unlock()
+	l.SetLimits(rate, burst)
+}
+
+// rateLimitState is a snapshot of a bucket after a request was counted.
+type rateLimitState struct {
+	disabled   bool
+	limit      int
+	allowed    bool
+	remaining  int
+	reset      time.Duration // until the bucket is full again
+	retryAfter time.Duration // until the next token, when not allowed
+}
+
+func (l *RateLimiter) take(key string, now time.Time) rateLimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return rateLimitState{disabled: true, allowed: true}
+	}
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	state := rateLimitState{limit: l.burst, allowed: b.tokens >= 1}
+	if state.allowed {
+		b.tokens--
+	} else {
+		state.retryAfter = l.durationFor(1 - b.tokens)
+	}
+	state.remaining = int(b.tokens)
+	state.reset = l.durationFor(float64(l.burst) - b.tokens)
+	return state
+}
+
+func (l *RateLimiter) durationFor(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have been idle long enough to be full again.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	idle := l.durationFor(float64(l.burst))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware enforces the limit and reports the caller's quota through
+// X-RateLimit-* headers on every response.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probePaths[r.URL.Path] || r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		state := l.take(remoteIP(r), time.Now())
+		if state.disabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(state.limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(state.remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(state.reset)))
+
+		if !state.allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(state.retryAfter)))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
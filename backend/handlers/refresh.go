@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// RefreshUserCache re-reads a user from the primary and overwrites its cache
+// entry, reconciling Redis after out-of-band changes to the database.
+func (h *UserHandler) RefreshUserCache(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	// The cached copy may still carry an email changed out of band, whose
+	// by-email entry must go too.
+	oldEmail := h.cachedUserEmail(r.Context(), id)
+
+	// Read from the primary: a replica may not have the change yet.
+	var user models.User
+	err := h.guard(func() error {
+		defer observeQuery(r.Context(), opSelectUser, time.Now())
+		return h.queryRowPrepared(r.Context(), h.DB, queryGetUser, []any{id}, userDest(&user)...)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.invalidateUsers(id)
+			h.invalidateUserEmails(oldEmail)
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			writeDBError(w, err)
+		}
+		return
+	}
+
+	h.invalidateUsers(id)
+	h.invalidateUserEmails(oldEmail, user.Email)
+	userJSON, err := h.cacheJSON(userKey(id), user, h.cacheTTL())
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeUser(w, r, userJSON)
+}
+
+// cachedUserEmail returns the email of id's cached entry, or "" if it is not
+// cached.
+func (h *UserHandler) cachedUserEmail(ctx context.Context, id int) string {
+	payload, _, err := h.cacheGet(ctx, userKey(id))
+	if err != nil {
+		return ""
+	}
+	var user models.User
+	json.Unmarshal(payload, &user)
+	return user.Email
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// replayBlockedPrefixes are paths a replay may not target: admin and debug
+// endpoints, which includes the replay endpoint itself.
+var replayBlockedPrefixes = []string{"/api/admin/", "/debug/"}
+
+// Replayer serves POST /api/admin/replay, running a described request
+// sequence against the local handlers in-process and reporting timings, so
+// a workload can be reproduced on one pod without an external load tool.
+type Replayer struct {
+	target http.Handler
+	// maxRequests caps the requests of one replay across all steps;
+	// maxConcurrency caps its workers.
+	maxRequests    int
+	maxConcurrency int
+	// running admits one replay at a time, so concurrent admin calls can't
+	// multiply the worker count.
+	running chan struct{}
+}
+
+func NewReplayer(target http.Handler, maxRequests, maxConcurrency int) *Replayer {
+	return &Replayer{target: target, maxRequests: maxRequests, maxConcurrency: maxConcurrency, running: make(chan struct{}, 1)}
+}
+
+// replayCall is one replayed request and, once sent, its outcome.
+type replayCall struct {
+	step    int
+	status  int
+	latency time.Duration
+}
+
+func (p *Replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.ReplayRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if err := p.validate(&req); err != nil {
+		http.Error(w, "Invalid replay: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case p.running <- struct{}{}:
+		defer func() { <-p.running }()
+	default:
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Another replay is running, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	var calls []replayCall
+	for i, step := range req.Steps {
+		for range step.Repeat {
+			calls = append(calls, replayCall{step: i})
+		}
+	}
+
+	ctx := r.Context()
+	start := time.Now()
+	next := make(chan *replayCall)
+	var wg sync.WaitGroup
+	for range req.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for call := range next {
+				step := req.Steps[call.step]
+				target := httptest.NewRequestWithContext(ctx, step.Method, step.Path, bytes.NewReader(step.Body))
+				if len(step.Body) > 0 {
+					target.Header.Set("Content-Type", "application/json")
+				}
+				for name, value := range step.Headers {
+					target.Header.Set(name, value)
+				}
+				rec := httptest.NewRecorder()
+				sent := time.Now()
+				p.target.ServeHTTP(rec, target)
+				call.latency = time.Since(sent)
+				call.status = rec.Code
+			}
+		}()
+	}
+	sent := 0
+	for i := range calls {
+		if ctx.Err() != nil {
+			break
+		}
+		next <- &calls[i]
+		sent++
+	}
+	close(next)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	result := replayResult(req.Steps, calls, elapsed)
+	result.Incomplete = sent < len(calls)
+	log.Printf("Replayed %d of %d requests in %v (from %s)", sent, len(calls), elapsed, remoteIP(r))
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (p *Replayer) validate(req *models.ReplayRequest) error {
+	if len(req.Steps) == 0 {
+		return fmt.Errorf("steps must not be empty")
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = 1
+	}
+	if req.Concurrency < 1 || req.Concurrency > p.maxConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d", p.maxConcurrency)
+	}
+
+	total := 0
+	for i := range req.Steps {
+		step := &req.Steps[i]
+		step.Method = strings.ToUpper(step.Method)
+		if step.Method == "" {
+			step.Method = http.MethodGet
+		}
+		if !strings.HasPrefix(step.Path, "/") {
+			return fmt.Errorf("step %d: path must start with /", i)
+		}
+		target, err := http.NewRequest(step.Method, step.Path, nil)
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+		// Match on the decoded path, as the mux does.
+		for _, prefix := range replayBlockedPrefixes {
+			if strings.HasPrefix(target.URL.Path, prefix) {
+				return fmt.Errorf("step %d: %s endpoints cannot be replayed", i, prefix)
+			}
+		}
+		if step.Repeat < 1 {
+			return fmt.Errorf("step %d: repeat must be positive", i)
+		}
+		total += step.Repeat
+	}
+	if total > p.maxRequests {
+		return fmt.Errorf("%d requests requested, at most %d allowed", total, p.maxRequests)
+	}
+	return nil
+}
+
+func replayResult(steps []models.ReplayStep, calls []replayCall, elapsed time.Duration) models.ReplayResult {
+	result := models.ReplayResult{
+		Duration: elapsed.String(),
+		Steps:    make([]models.ReplayStepStats, len(steps)),
+	}
+	latencies := make([][]time.Duration, len(steps))
+	for i, step := range steps {
+		result.Steps[i] = models.ReplayStepStats{Method: step.Method, Path: step.Path, Statuses: map[string]int{}}
+	}
+	for _, call := range calls {
+		if call.status == 0 {
+			continue // never sent
+		}
+		stats := &result.Steps[call.step]
+		stats.Requests++
+		stats.Statuses[strconv.Itoa(call.status)]++
+		latencies[call.step] = append(latencies[call.step], call.latency)
+		result.Requests++
+	}
+	for i, l := range latencies {
+		result.Steps[i].Latency = replayLatency(l)
+	}
+	if elapsed > 0 {
+		result.RequestsPerSecond = float64(result.Requests) / elapsed.Seconds()
+	}
+	return result
+}
+
+func replayLatency(latencies []time.Duration) models.ReplayLatency {
+	if len(latencies) == 0 {
+		return models.ReplayLatency{}
+	}
+	slices.Sort(latencies)
+	at := func(q float64) string {
+		return latencies[int(q*float64(len(latencies)-1))].String()
+	}
+	return models.ReplayLatency{Min: at(0), P50: at(0.5), P95: at(0.95), P99: at(0.99), Max: at(1)}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s-autoscale-webapp/models"
+)
+
+func newTestReplayer() *Replayer {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ok", func(w http.ResponseWriter, r *http.Request) {})
+	return NewReplayer(mux, 10, 4)
+}
+
+func replay(p *Replayer, ctx context.Context, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequestWithContext(ctx, "POST", "/api/admin/replay", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestReplayValidation(t *testing.T) {
+	p := newTestReplayer()
+	tests := map[string]string{
+		"no steps":          `{"steps": []}`,
+		"concurrency":       `{"steps": [{"path": "/ok", "repeat": 1}], "concurrency": 5}`,
+		"relative path":     `{"steps": [{"path": "ok", "repeat": 1}]}`,
+		"admin path":        `{"steps": [{"path": "/api/admin/settings", "repeat": 1}]}`,
+		"debug path":        `{"steps": [{"path": "/debug/runtime", "repeat": 1}]}`,
+		"encoded admin":     `{"steps": [{"path": "/api/%61dmin/replay", "repeat": 1}]}`,
+		"zero repeat":       `{"steps": [{"path": "/ok"}]}`,
+		"too many requests": `{"steps": [{"path": "/ok", "repeat": 6}, {"path": "/ok", "repeat": 5}]}`,
+	}
+	for name, body := range tests {
+		if rec := replay(p, context.Background(), body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d: %s", name, rec.Code, http.StatusBadRequest, rec.Body)
+		}
+	}
+}
+
+func TestReplayStats(t *testing.T) {
+	p := newTestReplayer()
+	rec := replay(p, context.Background(), `{"steps": [{"path": "/ok", "repeat": 3}, {"method": "post", "path": "/ok", "repeat": 2}], "concurrency": 2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	var result models.ReplayResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Requests != 5 || result.Incomplete || len(result.Steps) != 2 {
+		t.Fatalf("result = %+v, want 5 requests over 2 steps", result)
+	}
+	if got := result.Steps[0]; got.Requests != 3 || got.Statuses["200"] != 3 || got.Latency.P99 == "" {
+		t.Errorf("GET step = %+v, want three 200s with latencies", got)
+	}
+	if got := result.Steps[1]; got.Method != "POST" || got.Statuses["405"] != 2 {
+		t.Errorf("POST step = %+v, want two 405s", got)
+	}
+}
+
+func TestReplayCanceled(t *testing.T) {
+	p := newTestReplayer()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rec := replay(p, ctx, `{"steps": [{"path": "/ok", "repeat": 3}]}`)
+	var result models.ReplayResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Incomplete || result.Requests != 0 {
+		t.Errorf("result = %+v, want an incomplete replay with no requests", result)
+	}
+}
+
+func TestReplayOneAtATime(t *testing.T) {
+	p := newTestReplayer()
+	p.running <- struct{}{}
+
+	if rec := replay(p, context.Background(), `{"steps": [{"path": "/ok", "repeat": 1}]}`); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// withReader runs a read against the replica when one is configured and
+// retries on the primary if the replica errors. sql.ErrNoRows is retried too:
+// a user created moments ago may not have replicated yet. The whole read is
+// guarded by the DB circuit breaker.
+func (s *pgStore) withReader(read func(db *sql.DB) error) error {
+	return s.guard(func() error {
+		return s.readWithFallback(read)
+	})
+}
+
+func (s *pgStore) readWithFallback(read func(db *sql.DB) error) error {
+	if s.ReadDB == nil || s.ReadDB == s.DB {
+		return read(s.DB)
+	}
+
+	err := read(s.ReadDB)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		log.Printf("Read replica query failed, falling back to primary: %v", err)
+	}
+	return read(s.DB)
+}
+
+// userDest returns scan destinations matching userColumns.
+func userDest(u *models.User) []any {
+	return []any{&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.Version}
+}
+
+func scanUsers(rows *sql.Rows) ([]models.User, error) {
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(userDest(&user)...); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+
+	"github.com/sony/gobreaker"
+)
+
+// errVersionConflict is returned by UserRepository.Update when the user
+// exists but was modified since the expected version.
+var errVersionConflict = errors.New("version conflict")
+
+// UserRepository is the storage behind the core user endpoints. Lookups of a
+// missing user return sql.ErrNoRows.
+type UserRepository interface {
+	// List returns every user, newest first.
+	List(ctx context.Context) ([]models.User, error)
+	Get(ctx context.Context, id int) (models.User, error)
+	Create(ctx context.Context, name, email string) (models.User, error)
+	// Update applies req if the user is still at version and also returns
+	// the email the user had before.
+	Update(ctx context.Context, id, version int, req models.UpdateUserRequest) (models.User, string, error)
+	Delete(ctx context.Context, id int) error
+	Count(ctx context.Context) (int, error)
+}
+
+// pgStore holds the Postgres plumbing shared by user queries: the primary
+// and replica pools, prepared statements, circuit breaker and failover
+// monitor.
+type pgStore struct {
+	DB *sql.DB
+	// ReadDB serves SELECTs; it is the primary when no replica is configured.
+	ReadDB *sql.DB
+
+	stmts *stmtCache
+	// unprepared sends the hot queries without preparing them.
+	unprepared bool
+	breaker    *gobreaker.CircuitBreaker
+	// failover, if set, resets the DB pools after connection-level errors.
+	failover *FailoverMonitor
+}
+
+func newPGStore(db, readDB *sql.DB, breaker *gobreaker.CircuitBreaker) *pgStore {
+	if readDB == nil {
+		readDB = db
+	}
+	return &pgStore{DB: db, ReadDB: readDB, stmts: newStmtCache(), breaker: breaker}
+}
+
+// postgresUsers is the UserRepository on Postgres. Reads go to the replica
+// with primary fallback, and every call runs through the circuit breaker.
+type postgresUsers struct {
+	*pgStore
+}
+
+func (p postgresUsers) List(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	err := p.withReader(func(db *sql.DB) error {
+		defer observeQuery(ctx, opSelectUsers, time.Now())
+		rows, err := p.queryPrepared(ctx, db, queryListUsers)
+		if err != nil {
+			return err
+		}
+		users, err = scanUsers(rows)
+		return err
+	})
+	return users, err
+}
+
+func (p postgresUsers) Get(ctx context.Context, id int) (models.User, error) {
+	var user models.User
+	err := p.withReader(func(db *sql.DB) error {
+		defer observeQuery(ctx, opSelectUser, time.Now())
+		return p.queryRowPrepared(ctx, db, queryGetUser, []any{id}, userDest(&user)...)
+	})
+	return user, err
+}
+
+func (p postgresUsers) Create(ctx context.Context, name, email string) (models.User, error) {
+	user := models.User{Name: name, Email: email}
+	err := p.guard(func() error {
+		defer observeQuery(ctx, opInsertUser, time.Now())
+		return p.queryRowPrepared(ctx, p.DB, queryInsertUser, []any{name, email}, &user.ID, &user.CreatedAt, &user.Version)
+	})
+	return user, err
+}
+
+func (p postgresUsers) Update(ctx context.Context, id, version int, req models.UpdateUserRequest) (models.User, string, error) {
+	var user models.User
+	var oldEmail string
+	err := p.guard(func() error {
+		defer observeQuery(ctx, opUpdateUser, time.Now())
+		return p.DB.QueryRowContext(ctx, queryUpdateUser, updateArg(req.Name), updateArg(req.Email), id, version).
+			Scan(append(userDest(&user), &oldEmail)...)
+	})
+	if err != sql.ErrNoRows {
+		return user, oldEmail, err
+	}
+
+	// No row matched: tell a missing user from a stale version.
+	var exists bool
+	err = p.guard(func() error {
+		defer observeQuery(ctx, opSelectUserExists, time.Now())
+		return p.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", id).Scan(&exists)
+	})
+	switch {
+	case err != nil:
+		return user, "", err
+	case !exists:
+		return user, "", sql.ErrNoRows
+	default:
+		return user, "", errVersionConflict
+	}
+}
+
+func (p postgresUsers) Delete(ctx context.Context, id int) error {
+	return p.guard(func() error {
+		defer observeQuery(ctx, opDeleteUser, time.Now())
+		result, err := p.DB.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+func (p postgresUsers) Count(ctx context.Context) (int, error) {
+	var count int
+	err := p.withReader(func(db *sql.DB) error {
+		defer observeQuery(ctx, opCountUsers, time.Now())
+		return db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+	})
+	return count, err
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// maxRequestIDLength bounds an X-Request-ID accepted from the client.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestIDMiddleware tags each request with an ID, reusing a well-formed
+// X-Request-ID from the client (e.g. set by the ingress) or generating one,
+// and echoes it in the X-Request-ID response header.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestID returns the ID RequestIDMiddleware assigned, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID accepts non-empty printable ASCII without spaces, so IDs
+// are safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// fallbackRequestSeq numbers the IDs generated when crypto/rand fails.
+var fallbackRequestSeq atomic.Uint64
+
+// newRequestID returns 16 random hex digits. If crypto/rand fails it falls
+// back to the time and a counter, which is still unique within the process.
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x-%x", time.Now().UnixNano(), fallbackRequestSeq.Add(1))
+	}
+	return hex.EncodeToString(buf)
+}
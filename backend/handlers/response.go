@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// envelopeMediaType lets a client opt into the envelope per request when it
+// is not enabled server-wide.
+const envelopeMediaType = "application/vnd.webapp.envelope+json"
+
+func (h *UserHandler) useEnvelope(r *http.Request) bool {
+	return h.Config.ResponseEnvelope || strings.Contains(r.Header.Get("Accept"), envelopeMediaType)
+}
+
+// writeUsers writes a raw JSON array of users, wrapping it in an envelope at
+// response time so cached payloads stay unwrapped.
+func (h *UserHandler) writeUsers(w http.ResponseWriter, r *http.Request, payload []byte) {
+	if !h.useEnvelope(r) {
+		w.Write(payload)
+		return
+	}
+
+	var items []json.RawMessage
+	json.Unmarshal(payload, &items)
+	writeEnvelope(w, json.RawMessage(payload), models.Meta{Count: len(items)})
+}
+
+func (h *UserHandler) writeUser(w http.ResponseWriter, r *http.Request, payload []byte) {
+	if !h.useEnvelope(r) {
+		w.Write(payload)
+		return
+	}
+
+	writeEnvelope(w, json.RawMessage(payload), models.Meta{Count: 1})
+}
+
+func writeEnvelope(w http.ResponseWriter, data any, meta models.Meta) {
+	writeJSON(w, http.StatusOK, models.Envelope{Data: data, Meta: meta})
+}
+
+// preferMinimal reports whether r carries the RFC 7240 preference
+// Prefer: return=minimal.
+func preferMinimal(r *http.Request) bool {
+	value, ok := preference(r, "return")
+	return ok && strings.EqualFold(value, "minimal")
+}
+
+// preferPageSize returns the page size from Prefer: page-size=N. Malformed
+// values are ignored, as RFC 7240 requires of unsupported preferences.
+func preferPageSize(r *http.Request) (int, bool) {
+	value, ok := preference(r, "page-size")
+	if !ok {
+		return 0, false
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 1 {
+		return 0, false
+	}
+	return size, true
+}
+
+// preference returns the value of the named token in r's Prefer headers.
+func preference(r *http.Request, name string) (string, bool) {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			token, _, _ := strings.Cut(pref, ";")
+			key, value, _ := strings.Cut(strings.Join(strings.Fields(token), ""), "=")
+			if strings.EqualFold(key, name) {
+				return strings.Trim(value, `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+// applyPreference adds pref to the Preference-Applied header.
+func applyPreference(w http.ResponseWriter, pref string) {
+	if applied := w.Header().Get("Preference-Applied"); applied != "" {
+		pref = applied + ", " + pref
+	}
+	w.Header().Set("Preference-Applied", pref)
+}
+
+// writeContextError answers a request whose context ended before it was
+// served: 504 when its deadline expired, 503 when it was canceled, so it
+// never goes out as an empty 200.
+func writeContextError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+		return
+	}
+	http.Error(w, "Request canceled", http.StatusServiceUnavailable)
+}
+
+// writeDBError maps a database failure to a response: 504 when the request
+// deadline or DB_STATEMENT_TIMEOUT expired, 503 while the circuit breaker is open or a transaction
+// kept conflicting, 500 otherwise.
+func writeDBError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) || isQueryCanceled(err) {
+		http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+		return
+	}
+	if errors.Is(err, errTxConflict) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Concurrent update conflict, please retry", http.StatusServiceUnavailable)
+		return
+	}
+	if isBreakerRejection(err) {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "Database temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"k8s-autoscale-webapp/metrics"
+)
+
+// unmatchedRoute labels requests no mux pattern matched, keeping the route
+// label limited to registered patterns.
+const unmatchedRoute = "unmatched"
+
+// RouteSizeMiddleware records request and response body sizes by mux
+// pattern. It must wrap the ServeMux directly: the mux sets r.Pattern on the
+// request it is given, which is only visible here if no middleware in between
+// replaced the request.
+func RouteSizeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body *countingReader
+		if r.ContentLength < 0 {
+			body = &countingReader{ReadCloser: r.Body}
+			r.Body = body
+		}
+		rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rw, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = unmatchedRoute
+		}
+		requestSize := r.ContentLength
+		if body != nil {
+			requestSize = body.n
+		}
+		metrics.RequestSize.WithLabelValues(route).Observe(float64(requestSize))
+		metrics.ResponseSize.WithLabelValues(route).Observe(float64(rw.size))
+	})
+}
+
+// countingReader counts the bytes read from a request body of unknown
+// length.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// responseRecorder captures the status and body size of a response while
+// passing it through unchanged.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.wroteHeader = true
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(p)
+	rw.size += int64(n)
+	return n, err
+}
+
+func (rw *responseRecorder) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
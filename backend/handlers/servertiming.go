@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server-Timing metric names; db and cache sum every query and cache read of
+// the request.
+const (
+	timingDB    = "db"
+	timingCache = "cache"
+	timingTotal = "total"
+)
+
+type timingsKey struct{}
+
+// serverTimings accumulates the time a request spent per metric. It is
+// shared with anything running under the request context, hence the lock.
+type serverTimings struct {
+	mu    sync.Mutex
+	names []string
+	durs  map[string]time.Duration
+}
+
+// recordTiming adds d to the named metric of the request behind ctx; it is a
+// no-op outside ServerTimingMiddleware.
+func recordTiming(ctx context.Context, name string, d time.Duration) {
+	t, ok := ctx.Value(timingsKey{}).(*serverTimings)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, seen := t.durs[name]; !seen {
+		t.names = append(t.names, name)
+	}
+	t.durs[name] += d
+}
+
+// header formats the metrics in the order first recorded, then total, in
+// milliseconds.
+func (t *serverTimings) header(total time.Duration) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parts := make([]string, 0, len(t.names)+1)
+	for _, name := range t.names {
+		parts = append(parts, timingEntry(name, t.durs[name]))
+	}
+	parts = append(parts, timingEntry(timingTotal, total))
+	return strings.Join(parts, ", ")
+}
+
+func timingEntry(name string, d time.Duration) string {
+	return name + ";dur=" + strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 1, 64)
+}
+
+// ServerTimingMiddleware reports where a request's time went in a
+// Server-Timing header, e.g. "db;dur=12.1, cache;dur=0.8, total;dur=14.0".
+// total runs until the headers are written, so for streamed responses it is
+// the time to first byte, and for handlers that write nothing it is the time
+// the handler took.
+func ServerTimingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := &serverTimings{durs: map[string]time.Duration{}}
+		tw := &timingWriter{ResponseWriter: w, timings: t, start: time.Now()}
+		next.ServeHTTP(tw, r.WithContext(context.WithValue(r.Context(), timingsKey{}, t)))
+		// The server only sends the implicit 200 after the handler returns.
+		tw.setHeader()
+	})
+}
+
+// timingWriter sets the Server-Timing header just before the headers are
+// sent.
+type timingWriter struct {
+	http.ResponseWriter
+	timings     *serverTimings
+	start       time.Time
+	wroteHeader bool
+}
+
+func (tw *timingWriter) setHeader() {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.Header().Set("Server-Timing", tw.timings.header(time.Since(tw.start)))
+}
+
+func (tw *timingWriter) WriteHeader(status int) {
+	tw.setHeader()
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *timingWriter) Write(p []byte) (int, error) {
+	tw.setHeader()
+	return tw.ResponseWriter.Write(p)
+}
+
+func (tw *timingWriter) Flush() {
+	tw.setHeader()
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (tw *timingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"k8s-autoscale-webapp/models"
+	"k8s-autoscale-webapp/settings"
+)
+
+// SettingsHandler exposes the runtime-tunable parameters under
+// /api/admin/settings.
+type SettingsHandler struct {
+	Store *settings.Store
+}
+
+func NewSettingsHandler(store *settings.Store) *SettingsHandler {
+	return &SettingsHandler{Store: store}
+}
+
+func (h *SettingsHandler) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, h.Store.List())
+}
+
+func (h *SettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateSettingRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	h.write(w, h.Store.Set(r.Context(), r.PathValue("name"), req.Value))
+}
+
+func (h *SettingsHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	h.write(w, h.Store.Reset(r.Context(), r.PathValue("name")))
+}
+
+func (h *SettingsHandler) write(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, settings.ErrUnknownSetting):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, settings.ErrInvalidValue):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		writeDBError(w, err)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		writeJSON(w, http.StatusOK, h.Store.List())
+	}
+}
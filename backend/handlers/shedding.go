@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s-autoscale-webapp/metrics"
+)
+
+const (
+	// shedInterval is how often the shed rate is adjusted.
+	shedInterval = time.Second
+	// shedStep is added to the shed rate after an interval over target;
+	// the rate is halved after one under it.
+	shedStep = 0.1
+	// maxShedSamples bounds the latencies kept per interval.
+	maxShedSamples = 4096
+)
+
+// LoadShedder keeps latency bounded during a spike: when the p99 latency of
+// the last interval exceeds target it rejects a growing fraction of requests
+// with 503, and backs off once latency recovers (additive increase,
+// multiplicative decrease). Probes, metrics and admin endpoints are never
+// shed.
+type LoadShedder struct {
+	target  time.Duration
+	maxRate float64
+
+	mu      sync.Mutex
+	rate    float64
+	samples []time.Duration
+	// seen counts the requests observed this interval, including those not
+	// kept in samples.
+	seen       int
+	lastAdjust time.Time
+}
+
+func NewLoadShedder(target time.Duration, maxRate float64) *LoadShedder {
+	return &LoadShedder{target: target, maxRate: maxRate, lastAdjust: time.Now()}
+}
+
+func (s *LoadShedder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if neverShed(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.shed() {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Service overloaded, try again later", http.StatusServiceUnavailable)
+			return
+		}
+
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		// Stress runs are slow by design and would keep the p99 over
+		// target; they can be shed but don't drive the rate.
+		if !strings.HasPrefix(r.URL.Path, "/api/stress") {
+			s.observe(time.Since(start))
+		}
+	})
+}
+
+func neverShed(path string) bool {
+	return probePaths[path] || path == "/metrics" || strings.HasPrefix(path, "/api/admin/")
+}
+
+func (s *LoadShedder) shed() bool {
+	s.mu.Lock()
+	s.adjust()
+	rate := s.rate
+	s.mu.Unlock()
+	return rate > 0 && rand.Float64() < rate
+}
+
+// observe records a served request's latency.
+func (s *LoadShedder) observe(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seen++
+	if len(s.samples) < maxShedSamples {
+		s.samples = append(s.samples, latency)
+	} else if i := rand.IntN(s.seen); i < maxShedSamples {
+		s.samples[i] = latency
+	}
+	s.adjust()
+}
+
+// adjust updates the shed rate once per interval from the latencies sampled
+// since the last update. Intervals without samples, e.g. once traffic stops
+// or only stress requests arrive, each halve the rate so it decays to 0.
+// The caller holds mu.
+func (s *LoadShedder) adjust() {
+	elapsed := time.Since(s.lastAdjust)
+	if elapsed < shedInterval {
+		return
+	}
+
+	if len(s.samples) > 0 {
+		slices.Sort(s.samples)
+		p99 := s.samples[(len(s.samples)-1)*99/100]
+		if p99 > s.target {
+			s.rate = min(s.rate+shedStep, s.maxRate)
+		} else {
+			s.rate /= 2
+		}
+	} else {
+		for i := time.Duration(0); i < elapsed/shedInterval && s.rate > 0; i++ {
+			s.rate /= 2
+		}
+	}
+	if s.rate < 0.01 {
+		s.rate = 0
+	}
+	metrics.LoadShedRate.Set(s.rate)
+
+	s.samples = s.samples[:0]
+	s.seen = 0
+	s.lastAdjust = time.Now()
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// endInterval backdates the shedder's last adjustment by n intervals so the
+// next call adjusts the rate.
+func endInterval(s *LoadShedder, n int) {
+	s.lastAdjust = time.Now().Add(-time.Duration(n) * shedInterval)
+}
+
+func TestLoadShedderIncreases(t *testing.T) {
+	s := NewLoadShedder(10*time.Millisecond, 0.25)
+
+	for _, want := range []float64{0.1, 0.2, 0.25, 0.25} {
+		endInterval(s, 1)
+		s.observe(50 * time.Millisecond)
+		if math.Abs(s.rate-want) > 1e-9 {
+			t.Fatalf("rate = %v, want %v", s.rate, want)
+		}
+	}
+}
+
+func TestLoadShedderDecaysUnderTarget(t *testing.T) {
+	s := NewLoadShedder(10*time.Millisecond, 0.9)
+	s.rate = 0.4
+
+	endInterval(s, 1)
+	s.observe(time.Millisecond)
+	if s.rate != 0.2 {
+		t.Errorf("rate = %v, want 0.2", s.rate)
+	}
+}
+
+func TestLoadShedderDecaysWithoutSamples(t *testing.T) {
+	s := NewLoadShedder(10*time.Millisecond, 0.9)
+	s.rate = 0.4
+
+	// Three idle intervals halve the rate three times.
+	endInterval(s, 3)
+	s.shed()
+	if s.rate != 0.05 {
+		t.Errorf("rate = %v, want 0.05", s.rate)
+	}
+
+	// A long idle spell clears it.
+	endInterval(s, 10)
+	s.shed()
+	if s.rate != 0 {
+		t.Errorf("rate = %v, want 0", s.rate)
+	}
+}
+
+func TestLoadShedderMiddleware(t *testing.T) {
+	s := NewLoadShedder(10*time.Millisecond, 1)
+	s.rate = 1
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for path, want := range map[string]int{
+		"/api/users":          http.StatusServiceUnavailable,
+		"/api/stress":         http.StatusServiceUnavailable,
+		"/healthz":            http.StatusOK,
+		"/metrics":            http.StatusOK,
+		"/api/admin/settings": http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, want)
+		}
+	}
+}
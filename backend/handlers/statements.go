@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// userColumns lists the users columns in the order userDest scans them.
+const userColumns = "id, name, email, created_at, version"
+
+// Hot queries prepared once per database and reused across requests.
+const (
+	queryListUsers  = "SELECT " + userColumns + " FROM users ORDER BY created_at DESC"
+	queryGetUser    = "SELECT " + userColumns + " FROM users WHERE id = $1"
+	queryInsertUser = "INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id, created_at, version"
+)
+
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+// stmtCache lazily prepares statements and drops them when Postgres reports
+// they are no longer valid, so the next use re-prepares.
+type stmtCache struct {
+	mu    sync.Mutex
+	stmts map[stmtKey]*sql.Stmt
+}
+
+func newStmtCache() *stmtCache {
+	return &stmtCache{stmts: make(map[stmtKey]*sql.Stmt)}
+}
+
+// get returns the statement for query on db, preparing it under ctx if it
+// is not cached yet. The lock is not held while preparing, so one slow
+// Prepare during an outage does not stall every other statement; when two
+// callers prepare the same query, the loser closes its copy.
+func (c *stmtCache) get(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, error) {
+	key := stmtKey{db, query}
+	c.mu.Lock()
+	stmt, ok := c.stmts[key]
+	c.mu.Unlock()
+	if ok {
+		return stmt, nil
+	}
+
+	prepared, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stmt, ok := c.stmts[key]; ok {
+		prepared.Close()
+		return stmt, nil
+	}
+	c.stmts[key] = prepared
+	return prepared, nil
+}
+
+func (c *stmtCache) invalidate(db *sql.DB, query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := stmtKey{db, query}
+	if stmt, ok := c.stmts[key]; ok {
+		stmt.Close()
+		delete(c.stmts, key)
+	}
+}
+
+func (c *stmtCache) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, stmt := range c.stmts {
+		stmt.Close()
+		delete(c.stmts, key)
+	}
+}
+
+// isStaleStatement reports whether err means a prepared statement must be
+// re-prepared: it was dropped server-side (26000) or its cached plan no
+// longer matches the schema (0A000).
+func isStaleStatement(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "26000" || pqErr.Code == "0A000"
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation
+// (23505), e.g. a duplicate email.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isTxConflict reports whether err is a deadlock (40P01) or serialization
+// failure (40001), after which the whole transaction can be retried.
+func isTxConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40P01" || pqErr.Code == "40001")
+}
+
+// PrepareStatements prepares the hot queries up front. Failures are logged
+// and left to be retried lazily on first use.
+func (s *pgStore) PrepareStatements(ctx context.Context) {
+	for _, db := range []*sql.DB{s.DB, s.ReadDB} {
+		for _, query := range []string{queryListUsers, queryGetUser, queryInsertUser} {
+			if _, err := s.stmts.get(ctx, db, query); err != nil {
+				log.Printf("Failed to prepare statement, will retry on use: %v", err)
+				continue
+			}
+		}
+	}
+}
+
+// DisablePreparedStatements sends the hot queries as plain queries, so their
+// db_query_duration_seconds can be compared with the prepared ones.
+func (s *pgStore) DisablePreparedStatements() {
+	s.unprepared = true
+}
+
+// Close releases all prepared statements.
+func (s *pgStore) Close() {
+	s.stmts.Close()
+}
+
+func (s *pgStore) queryPrepared(ctx context.Context, db *sql.DB, query string, args ...any) (*sql.Rows, error) {
+	if s.unprepared {
+		return db.QueryContext(ctx, query, args...)
+	}
+	stmt, err := s.stmts.get(ctx, db, query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if isStaleStatement(err) {
+		s.stmts.invalidate(db, query)
+		if stmt, err = s.stmts.get(ctx, db, query); err != nil {
+			return nil, err
+		}
+		rows, err = stmt.QueryContext(ctx, args...)
+	}
+	return rows, err
+}
+
+func (s *pgStore) queryRowPrepared(ctx context.Context, db *sql.DB, query string, args []any, dest ...any) error {
+	if s.unprepared {
+		return db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	}
+	stmt, err := s.stmts.get(ctx, db, query)
+	if err != nil {
+		return err
+	}
+
+	err = stmt.QueryRowContext(ctx, args...).Scan(dest...)
+	if isStaleStatement(err) {
+		s.stmts.invalidate(db, query)
+		if stmt, err = s.stmts.get(ctx, db, query); err != nil {
+			return err
+		}
+		err = stmt.QueryRowContext(ctx, args...).Scan(dest...)
+	}
+	return err
+}
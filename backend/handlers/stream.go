@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s-autoscale-webapp/metrics"
+	"k8s-autoscale-webapp/models"
+)
+
+// eventStreamPaths are the endpoints JSONOnlyMiddleware lets through for
+// clients asking for text/event-stream.
+var eventStreamPaths = map[string]bool{
+	"/api/stress": true,
+}
+
+// defaultProgressInterval applies when StressHandler.ProgressInterval is unset.
+const defaultProgressInterval = time.Second
+
+// progressMilestone also reports progress every tenth of the iterations, so
+// runs shorter than the interval still show progress.
+const progressMilestone = 10
+
+func wantsEventStream(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), "text/event-stream") {
+			return true
+		}
+	}
+	return false
+}
+
+// streamCPU runs the CPU stress as Server-Sent Events: a "progress" event
+// per warm-up step and a "ramp" event once the warm-up finishes, then a
+// "progress" event every ProgressInterval or tenth of the iterations,
+// whichever comes first, and a final "complete" event carrying the usual
+// JSON response. A client that disconnects stops the run.
+func (h *StressHandler) streamCPU(w http.ResponseWriter, r *http.Request, ramp time.Duration) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Stress event stream: %v", err)
+		return
+	}
+
+	ctx := r.Context()
+	send := func(event string, v any) bool {
+		data, err := json.Marshal(v)
+		if err == nil {
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		return err == nil
+	}
+
+	start := time.Now()
+	response := models.StressTestResponse{Message: "Stress test completed", Iterations: defaultStressIterations}
+	if ramp > 0 {
+		response.Ramp = rampCPU(ctx, ramp, func(step int, duty float64) bool {
+			return send("progress", models.StressProgress{
+				Phase:     "ramp",
+				Percent:   100 * float64(step) / rampSteps,
+				Elapsed:   time.Since(start).String(),
+				DutyCycle: duty,
+			})
+		})
+		if ctx.Err() != nil {
+			metrics.StressAbandoned.WithLabelValues("cpu").Inc()
+			return
+		}
+		if !send("ramp", response.Ramp) {
+			return
+		}
+	}
+
+	interval := h.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	next := time.Now().Add(interval)
+	milestone := max(response.Iterations/progressMilestone, 1)
+	nextMilestone := milestone
+	done := 0
+	for done < response.Iterations {
+		chunk := min(cancelCheckInterval, response.Iterations-done)
+		// burnCPU sums 0..chunk-1; shift it to done..done+chunk-1 so the
+		// total matches a single run over all iterations.
+		response.Result += burnCPU(chunk) + done*chunk
+		done += chunk
+
+		if ctx.Err() != nil {
+			metrics.StressAbandoned.WithLabelValues("cpu").Inc()
+			return
+		}
+		if now := time.Now(); (!now.Before(next) || done >= nextMilestone) && done < response.Iterations {
+			next = now.Add(interval)
+			for nextMilestone <= done {
+				nextMilestone += milestone
+			}
+			if !send("progress", models.StressProgress{
+				Phase:      "burn",
+				Percent:    100 * float64(done) / float64(response.Iterations),
+				Elapsed:    now.Sub(start).String(),
+				Iterations: done,
+				Result:     response.Result,
+			}) {
+				return
+			}
+		}
+	}
+
+	send("complete", response)
+}
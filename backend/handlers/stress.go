@@ -1,32 +1,216 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
 
+	"k8s-autoscale-webapp/metrics"
 	"k8s-autoscale-webapp/models"
 )
 
-type StressHandler struct{}
+// maxMemoryHold caps how long the memory stress mode keeps its allocation.
+const maxMemoryHold = 30 * time.Second
 
-func NewStressHandler() *StressHandler {
-	return &StressHandler{}
+const (
+	// maxRamp caps the ?ramp= warm-up period.
+	maxRamp = 60 * time.Second
+	// rampSteps is how many equal steps the ramp climbs through; rampSlice
+	// is the busy/idle period the duty cycle is applied over.
+	rampSteps = 10
+	rampSlice = 10 * time.Millisecond
+)
+
+type StressHandler struct {
+	// maxMemoryMB is the largest allocation the memory mode accepts, and
+	// the budget all concurrent memory stress runs share.
+	maxMemoryMB int
+	// reservedMB is the part of the budget held by running allocations.
+	reservedMB atomic.Int64
+	// db serves the DB dimension of mixed stress runs.
+	db *sql.DB
+	// ProgressInterval spaces progress events for streaming clients.
+	ProgressInterval time.Duration
+	// DBQueryTimeout bounds each query of the DB dimension; 0 leaves only
+	// the request deadline.
+	DBQueryTimeout time.Duration
+}
+
+func NewStressHandler(maxMemoryMB int, db *sql.DB) *StressHandler {
+	return &StressHandler{maxMemoryMB: maxMemoryMB, db: db}
 }
 
 func (h *StressHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// CPU intensive operation for testing HPA
-	iterations := 100000000
+	if r.URL.Query().Get("mode") == "memory" {
+		h.stressMemory(w, r)
+		return
+	}
+
+	var ramp time.Duration
+	if v := r.URL.Query().Get("ramp"); v != "" {
+		var err error
+		if ramp, err = time.ParseDuration(v); err != nil || ramp < 0 || ramp > maxRamp {
+			http.Error(w, "Invalid ramp: must be a duration up to "+maxRamp.String(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if wantsEventStream(r) {
+		h.streamCPU(w, r, ramp)
+		return
+	}
+
+	response := models.StressTestResponse{Message: "Stress test completed"}
+	if ramp > 0 {
+		response.Ramp = rampCPU(r.Context(), ramp, nil)
+	}
+
+	// CPU intensive operation for testing HPA, stopped as soon as the
+	// client gives up so cancelled runs don't keep the pod busy.
+	response.Iterations = defaultStressIterations
+	result, err := burnCPUContext(r.Context(), response.Iterations)
+	if err != nil {
+		abandonStress(w, "cpu", err)
+		return
+	}
+	response.Result = result
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, response)
+}
+
+// abandonStress ends a stress run whose request was cancelled: a deadline
+// from X-Request-Timeout answers 504, a disconnected client gets nothing.
+func abandonStress(w http.ResponseWriter, mode string, err error) {
+	metrics.StressAbandoned.WithLabelValues(mode).Inc()
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+	}
+}
+
+func burnCPU(iterations int) int {
 	result := 0
 	for i := 0; i < iterations; i++ {
 		result += i
 	}
+	return result
+}
+
+// cancelCheckInterval is how many iterations burnCPUContext runs between
+// context checks.
+const cancelCheckInterval = 1 << 20
+
+// burnCPUContext is burnCPU that stops early with ctx's error once ctx is
+// done.
+func burnCPUContext(ctx context.Context, iterations int) (int, error) {
+	result := 0
+	for i := 0; i < iterations; i++ {
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		result += i
+	}
+	return result, nil
+}
+
+// rampCPU raises CPU load in rampSteps equal steps over ramp, busy-looping
+// for a growing share of each rampSlice, so load climbs gradually instead of
+// jumping straight to 100%. onStep, if set, is called after each step and
+// can stop the ramp by returning false.
+func rampCPU(ctx context.Context, ramp time.Duration, onStep func(step int, duty float64) bool) *models.StressRamp {
+	profile := &models.StressRamp{Duration: ramp.String()}
+	step := ramp / rampSteps
+
+	for i := 1; i <= rampSteps && ctx.Err() == nil; i++ {
+		duty := float64(i) / (rampSteps + 1)
+		profile.DutyCycles = append(profile.DutyCycles, duty)
+
+		busy := time.Duration(duty * float64(rampSlice))
+		for end := time.Now().Add(step); time.Now().Before(end) && ctx.Err() == nil; {
+			for spin := time.Now().Add(busy); time.Now().Before(spin); {
+			}
+			time.Sleep(rampSlice - busy)
+		}
+		if onStep != nil && ctx.Err() == nil && !onStep(i, duty) {
+			break
+		}
+	}
+	return profile
+}
+
+// reserveMemory takes mb from the budget shared by all memory stress runs,
+// answering 503 when it is exhausted: each run fits the ceiling on its own,
+// but several at once could still get the pod OOM-killed.
+func (h *StressHandler) reserveMemory(w http.ResponseWriter, mb int) bool {
+	for {
+		reserved := h.reservedMB.Load()
+		if reserved+int64(mb) > int64(h.maxMemoryMB) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, fmt.Sprintf("Memory stress budget exhausted: %d of %d MB in use", reserved, h.maxMemoryMB), http.StatusServiceUnavailable)
+			return false
+		}
+		if h.reservedMB.CompareAndSwap(reserved, reserved+int64(mb)) {
+			return true
+		}
+	}
+}
+
+func (h *StressHandler) releaseMemory(mb int) {
+	h.reservedMB.Add(-int64(mb))
+}
+
+// stressMemory allocates ?mb= megabytes, touches every page so it counts
+// towards the pod's working set, and holds it for ?hold= (default 1s).
+func (h *StressHandler) stressMemory(w http.ResponseWriter, r *http.Request) {
+	mb, err := strconv.Atoi(r.URL.Query().Get("mb"))
+	if err != nil || mb <= 0 {
+		http.Error(w, "Invalid mb: must be a positive integer", http.StatusBadRequest)
+		return
+	}
+	if mb > h.maxMemoryMB {
+		http.Error(w, fmt.Sprintf("Requested %d MB exceeds the safe ceiling of %d MB", mb, h.maxMemoryMB), http.StatusBadRequest)
+		return
+	}
+
+	hold := time.Second
+	if v := r.URL.Query().Get("hold"); v != "" {
+		if hold, err = time.ParseDuration(v); err != nil || hold < 0 || hold > maxMemoryHold {
+			http.Error(w, "Invalid hold: must be a duration up to "+maxMemoryHold.String(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !h.reserveMemory(w, mb) {
+		return
+	}
+	defer h.releaseMemory(mb)
+
+	buf := make([]byte, mb<<20)
+	for i := 0; i < len(buf); i += 4096 {
+		buf[i] = 1
+	}
+
+	select {
+	case <-time.After(hold):
+	case <-r.Context().Done():
+		runtime.KeepAlive(buf)
+		abandonStress(w, "memory", r.Context().Err())
+		return
+	}
+	runtime.KeepAlive(buf)
 
 	response := models.StressTestResponse{
-		Message:    "Stress test completed",
-		Result:     result,
-		Iterations: iterations,
+		Message:  "Memory stress test completed",
+		Mode:     "memory",
+		MemoryMB: mb,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
\ No newline at end of file
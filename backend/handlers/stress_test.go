@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+func TestStressMemorySharedBudget(t *testing.T) {
+	h := NewStressHandler(8, nil)
+	h.reservedMB.Store(6)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/stress?mode=memory&mb=4&hold=0s", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/stress?mode=memory&mb=2&hold=0s", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if got := h.reservedMB.Load(); got != 6 {
+		t.Errorf("reserved = %d MB after the run, want 6", got)
+	}
+}
+
+func TestMixedStressSharedBudget(t *testing.T) {
+	h := NewStressHandler(8, nil)
+	h.reservedMB.Store(6)
+
+	rec := httptest.NewRecorder()
+	h.Mixed(rec, httptest.NewRequest("POST", "/api/stress/mixed", strings.NewReader(`{"memory_mb": 4}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if got := h.reservedMB.Load(); got != 6 {
+		t.Errorf("reserved = %d MB, want 6", got)
+	}
+}
+
+func TestStreamCPUProgress(t *testing.T) {
+	h := NewStressHandler(8, nil)
+	// An interval longer than the run: burn progress must come from the
+	// iteration milestones.
+	h.ProgressInterval = time.Hour
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	req, _ := http.NewRequest("GET", srv.URL+"/api/stress?ramp=100ms", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var events []string
+	phases := map[string]int{}
+	var event string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+			events = append(events, name)
+		} else if data, ok := strings.CutPrefix(line, "data: "); ok && event == "progress" {
+			var progress models.StressProgress
+			if err := json.Unmarshal([]byte(data), &progress); err != nil {
+				t.Fatal(err)
+			}
+			phases[progress.Phase]++
+		}
+	}
+
+	if phases["ramp"] != rampSteps {
+		t.Errorf("ramp progress events = %d, want %d", phases["ramp"], rampSteps)
+	}
+	if phases["burn"] < progressMilestone-1 {
+		t.Errorf("burn progress events = %d, want at least %d", phases["burn"], progressMilestone-1)
+	}
+	if len(events) == 0 || events[len(events)-1] != "complete" {
+		t.Errorf("events = %v, want a final complete", events)
+	}
+}
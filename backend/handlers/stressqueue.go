@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s-autoscale-webapp/metrics"
+	"k8s-autoscale-webapp/models"
+)
+
+const (
+	defaultStressIterations = 100000000
+	maxStressIterations     = 10000000000
+
+	// stressJobRetention is how long finished jobs stay queryable.
+	stressJobRetention = 10 * time.Minute
+)
+
+// StressQueue runs asynchronously submitted CPU stress jobs on a fixed pool
+// of workers, so queued jobs cannot all burn CPU at once and starve probes.
+type StressQueue struct {
+	queue chan *models.StressJob
+	// ctx is cancelled by Close, stopping the workers and running jobs.
+	ctx  context.Context
+	stop context.CancelFunc
+
+	mu   sync.Mutex
+	jobs map[string]*models.StressJob
+	// cancels holds the cancel func of each running job.
+	cancels map[string]context.CancelFunc
+}
+
+func NewStressQueue(workers, queueSize int) *StressQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &StressQueue{
+		queue:   make(chan *models.StressJob, queueSize),
+		ctx:     ctx,
+		stop:    cancel,
+		jobs:    make(map[string]*models.StressJob),
+		cancels: make(map[string]context.CancelFunc),
+	}
+	for i := 0; i < max(workers, 1); i++ {
+		go q.work()
+	}
+	return q
+}
+
+func (q *StressQueue) work() {
+	for {
+		var job *models.StressJob
+		select {
+		case job = <-q.queue:
+		case <-q.ctx.Done():
+			return
+		}
+		metrics.StressQueueDepth.Set(float64(len(q.queue)))
+
+		ctx, ok := q.start(job)
+		if !ok {
+			continue
+		}
+
+		result, err := burnCPUContext(ctx, job.Iterations)
+
+		q.mu.Lock()
+		q.cancels[job.ID]()
+		delete(q.cancels, job.ID)
+		job.FinishedAt = time.Now()
+		if err != nil {
+			job.Status = models.StressJobCancelled
+		} else {
+			job.Status = models.StressJobCompleted
+			job.Result = result
+		}
+		q.mu.Unlock()
+	}
+}
+
+// start marks job running and returns its context, or false if the job was
+// cancelled while queued.
+func (q *StressQueue) start(job *models.StressJob) (context.Context, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if job.Status != models.StressJobQueued {
+		return nil, false
+	}
+	ctx, cancel := context.WithCancel(q.ctx)
+	q.cancels[job.ID] = cancel
+	job.Status = models.StressJobRunning
+	job.StartedAt = time.Now()
+	return ctx, true
+}
+
+// Close stops the workers and cancels running jobs. Queued jobs are
+// abandoned rather than delaying shutdown.
+func (q *StressQueue) Close() {
+	q.stop()
+}
+
+// Submit handles POST /api/stress/async?iterations=N, answering 202 with the
+// job or 429 when the queue is full.
+func (q *StressQueue) Submit(w http.ResponseWriter, r *http.Request) {
+	iterations := defaultStressIterations
+	if v := r.URL.Query().Get("iterations"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxStressIterations {
+			http.Error(w, "Invalid iterations: must be between 1 and "+strconv.Itoa(maxStressIterations), http.StatusBadRequest)
+			return
+		}
+		iterations = n
+	}
+
+	job := &models.StressJob{
+		ID:         newJobID(),
+		Status:     models.StressJobQueued,
+		Iterations: iterations,
+		QueuedAt:   time.Now(),
+	}
+
+	q.mu.Lock()
+	q.pruneLocked(job.QueuedAt)
+	select {
+	case q.queue <- job:
+		q.jobs[job.ID] = job
+	default:
+		q.mu.Unlock()
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "Stress queue is full", http.StatusTooManyRequests)
+		return
+	}
+	snapshot := q.snapshotLocked(job)
+	q.mu.Unlock()
+	metrics.StressQueueDepth.Set(float64(snapshot.QueueDepth))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/stress/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+// Status handles GET /api/stress/jobs/{id}.
+func (q *StressQueue) Status(w http.ResponseWriter, r *http.Request) {
+	q.mu.Lock()
+	job, ok := q.jobs[r.PathValue("id")]
+	var snapshot models.StressJob
+	if ok {
+		snapshot = q.snapshotLocked(job)
+	}
+	q.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "Stress job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// List handles GET /api/stress/jobs, returning active and recently finished
+// jobs, newest first.
+func (q *StressQueue) List(w http.ResponseWriter, r *http.Request) {
+	q.mu.Lock()
+	q.pruneLocked(time.Now())
+	list := models.StressJobList{
+		Jobs:       make([]models.StressJob, 0, len(q.jobs)),
+		QueueDepth: len(q.queue),
+	}
+	for _, job := range q.jobs {
+		list.Jobs = append(list.Jobs, q.snapshotLocked(job))
+	}
+	q.mu.Unlock()
+
+	sort.Slice(list.Jobs, func(i, j int) bool {
+		return list.Jobs[i].QueuedAt.After(list.Jobs[j].QueuedAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Cancel handles DELETE /api/stress/jobs/{id}. A queued job is dropped
+// before it starts; a running one stops at its next cancellation check and
+// frees its worker. Finished jobs answer 409.
+func (q *StressQueue) Cancel(w http.ResponseWriter, r *http.Request) {
+	q.mu.Lock()
+	job, ok := q.jobs[r.PathValue("id")]
+	var snapshot models.StressJob
+	status := http.StatusOK
+	switch {
+	case !ok:
+		status = http.StatusNotFound
+	case job.Status == models.StressJobQueued:
+		job.Status = models.StressJobCancelled
+		job.FinishedAt = time.Now()
+	case job.Status == models.StressJobRunning:
+		q.cancels[job.ID]()
+	default:
+		status = http.StatusConflict
+	}
+	if ok {
+		snapshot = q.snapshotLocked(job)
+	}
+	q.mu.Unlock()
+
+	switch status {
+	case http.StatusNotFound:
+		http.Error(w, "Stress job not found", http.StatusNotFound)
+		return
+	case http.StatusConflict:
+		http.Error(w, "Stress job already "+snapshot.Status, http.StatusConflict)
+		return
+	}
+
+	if snapshot.Status == models.StressJobRunning {
+		// Reported as cancelling until the worker observes the cancellation.
+		snapshot.Status = models.StressJobCancelling
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+func (q *StressQueue) snapshotLocked(job *models.StressJob) models.StressJob {
+	snapshot := *job
+	snapshot.QueueDepth = len(q.queue)
+	return snapshot
+}
+
+// pruneLocked forgets jobs that finished more than stressJobRetention ago.
+func (q *StressQueue) pruneLocked(now time.Time) {
+	for id, job := range q.jobs {
+		if !job.FinishedAt.IsZero() && now.Sub(job.FinishedAt) > stressJobRetention {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+func newJobID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"k8s-autoscale-webapp/metrics"
+)
+
+const (
+	// maxTxAttempts bounds how often a transaction that hit a deadlock or
+	// serialization failure is run.
+	maxTxAttempts = 3
+	// txRetryBackoff is the base delay before a retry; it doubles per
+	// attempt and is jittered so conflicting clients don't collide again.
+	txRetryBackoff = 20 * time.Millisecond
+)
+
+// errTxConflict wraps the last error of a transaction that kept
+// conflicting; writeDBError maps it to 503.
+var errTxConflict = errors.New("transaction conflict")
+
+// retryTx runs tx until it succeeds, fails with an error other than a
+// deadlock or serialization failure, or maxTxAttempts are used up. tx must
+// begin and finish its own transaction so each attempt starts clean.
+func retryTx(ctx context.Context, op string, tx func() error) error {
+	backoff := txRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := tx()
+		if !isTxConflict(err) {
+			return err
+		}
+		if attempt >= maxTxAttempts {
+			return fmt.Errorf("%w after %d attempts: %w", errTxConflict, attempt, err)
+		}
+		metrics.DBTxRetries.WithLabelValues(op).Inc()
+
+		timer := time.NewTimer(backoff/2 + time.Duration(rand.Int63n(int64(backoff))))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// queryUpdateUser applies a versioned update and also returns the email
+// the row had before, so caches keyed by the old email can be dropped. A
+// NULL name or email keeps the current value.
+const queryUpdateUser = `UPDATE users SET name = COALESCE($1, name), email = COALESCE($2, email), version = version + 1
+	WHERE id = $3 AND version = $4
+	RETURNING ` + userColumns + `, (SELECT email FROM users WHERE id = $3)`
+
+// UpdateUser changes a user's name and email using optimistic concurrency:
+// the caller must send the version it last read, via If-Match or the body,
+// and gets 409 if another writer got there first. PUT replaces both fields;
+// PATCH changes only those present. An explicit null is rejected with 422,
+// except that it clears the name when UPDATE_NULL_CLEARS is set.
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if errs := req.Validate(r.Method == http.MethodPatch, h.Config.UpdateNullClears); errs != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, models.ValidationResponse{Errors: errs})
+		return
+	}
+
+	version, ok, err := expectedVersion(r, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		http.Error(w, "Missing version: send If-Match or a version field", http.StatusPreconditionRequired)
+		return
+	}
+
+	user, oldEmail, err := h.Users.Update(r.Context(), id, version, req)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case errors.Is(err, errVersionConflict):
+		http.Error(w, "Version conflict: user was modified concurrently", http.StatusConflict)
+		return
+	case isUniqueViolation(err):
+		http.Error(w, "Email already in use", http.StatusConflict)
+		return
+	case err != nil:
+		writeDBError(w, err)
+		return
+	}
+
+	h.invalidateUsers(id)
+	h.invalidateUserEmails(oldEmail, user.Email)
+
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(user.Version)))
+	writeJSON(w, http.StatusOK, user)
+}
+
+// updateArg maps an optional field to its queryUpdateUser argument: nil
+// keeps the column, null clears it to "" and a value sets it.
+func updateArg(field models.Optional[string]) any {
+	switch {
+	case !field.Set:
+		return nil
+	case field.Null:
+		return ""
+	default:
+		return field.Value
+	}
+}
+
+// expectedVersion reads the version precondition, preferring If-Match
+// (an ETag such as "3") over the body field.
+func expectedVersion(r *http.Request, req models.UpdateUserRequest) (int, bool, error) {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		tag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+		version, err := strconv.Atoi(tag)
+		if err != nil {
+			return 0, false, errInvalidIfMatch
+		}
+		return version, true, nil
+	}
+	if req.Version != nil {
+		return *req.Version, true, nil
+	}
+	return 0, false, nil
+}
+
+var errInvalidIfMatch = errors.New("invalid If-Match: expected a quoted version number")
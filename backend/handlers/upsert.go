@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+const maxUpsertBatch = 500
+
+// queryUpsertUser inserts or renames a user by email. xmax is 0 for freshly
+// inserted tuples and set for updated ones.
+const queryUpsertUser = `INSERT INTO users (name, email) VALUES ($1, $2)
+	ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name, version = users.version + 1
+	RETURNING id, created_at, version, (xmax = 0) AS inserted`
+
+// UpsertUsers inserts or renames users by email in a single transaction so
+// seeding the same dataset repeatedly is idempotent. Deadlocks with
+// concurrent batches are retried.
+func (h *UserHandler) UpsertUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var reqs []models.CreateUserRequest
+	if err := decodeJSON(r, &reqs); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > maxUpsertBatch {
+		http.Error(w, fmt.Sprintf("Batch must contain between 1 and %d users", maxUpsertBatch), http.StatusBadRequest)
+		return
+	}
+	// Each element is validated as CreateUser would; field errors are keyed
+	// by position, e.g. "[2].email".
+	errs := models.FieldErrors{}
+	for i := range reqs {
+		for field, problem := range h.prepareCreate(&reqs[i]) {
+			errs[fmt.Sprintf("[%d].%s", i, field)] = problem
+		}
+	}
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, models.ValidationResponse{Errors: errs})
+		return
+	}
+	if h.quotaExceeded(r.Context(), w, len(reqs)) {
+		return
+	}
+
+	response := models.UpsertResponse{Users: make([]models.UpsertedUser, 0, len(reqs))}
+	err := h.guard(func() error {
+		return retryTx(r.Context(), opUpsertUsers, func() error {
+			defer observeQuery(r.Context(), opUpsertUsers, time.Now())
+			response.Users = response.Users[:0]
+
+			tx, err := h.DB.BeginTx(r.Context(), nil)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+
+			stmt, err := tx.PrepareContext(r.Context(), queryUpsertUser)
+			if err != nil {
+				return err
+			}
+			defer stmt.Close()
+
+			for _, req := range reqs {
+				user := models.UpsertedUser{User: models.User{Name: req.Name, Email: req.Email}}
+				if err := stmt.QueryRowContext(r.Context(), req.Name, req.Email).Scan(&user.ID, &user.CreatedAt, &user.Version, &user.Inserted); err != nil {
+					return err
+				}
+				response.Users = append(response.Users, user)
+			}
+			return tx.Commit()
+		})
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	ids := make([]int, 0, len(response.Users))
+	emails := make([]string, 0, len(response.Users))
+	for _, user := range response.Users {
+		if user.Inserted {
+			response.Inserted++
+		} else {
+			response.Updated++
+		}
+		ids = append(ids, user.ID)
+		emails = append(emails, user.Email)
+	}
+	h.invalidateUsers(ids...)
+	h.invalidateUserEmails(emails...)
+	h.usersAdded(response.Inserted)
+
+	writeJSON(w, http.StatusOK, response)
+}
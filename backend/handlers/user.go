@@ -3,129 +3,416 @@ package handlers
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"k8s-autoscale-webapp/cache"
+	"k8s-autoscale-webapp/config"
 	"k8s-autoscale-webapp/models"
+	"k8s-autoscale-webapp/webhook"
 
-	"github.com/go-redis/redis/v8"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/sony/gobreaker"
 )
 
+// UserHandler serves the user endpoints. The core reads and writes go
+// through Users; listing variants such as pagination, projections and batch
+// reads query the embedded pgStore directly.
 type UserHandler struct {
-	DB  *sql.DB
-	RDB *redis.Client
-	Ctx context.Context
+	*pgStore
+	// Users is the storage for list, get, create and update.
+	Users  UserRepository
+	Cache  cache.Cache
+	Ctx    context.Context
+	Config config.APIConfig
+
+	notifier *webhook.Notifier
+	// l1 is an optional per-pod cache of user payloads in front of Redis.
+	l1 *expirable.LRU[int, []byte]
+	// ttl is Config.CacheTTL, adjustable at runtime via SetCacheTTL.
+	ttl atomic.Int64
+	// revalidating holds the cache keys with a background refresh running.
+	revalidating sync.Map
+	// oom tracks Redis out-of-memory rejections that pause cache writes.
+	oom cacheOOM
+	// users caches the user count checked against Config.MaxUsers.
+	users userCount
 }
 
-func NewUserHandler(db *sql.DB, rdb *redis.Client, ctx context.Context) *UserHandler {
-	return &UserHandler{
-		DB:  db,
-		RDB: rdb,
-		Ctx: ctx,
+func NewUserHandler(db, readDB *sql.DB, c cache.Cache, ctx context.Context, cfg config.APIConfig, breaker *gobreaker.CircuitBreaker, notifier *webhook.Notifier) *UserHandler {
+	var l1 *expirable.LRU[int, []byte]
+	// L1 entries are invalidated across pods through Redis, so the L1
+	// cache is only safe alongside it.
+	if cfg.L1CacheSize > 0 && cache.Enabled(c) {
+		l1 = expirable.NewLRU[int, []byte](cfg.L1CacheSize, nil, cfg.L1CacheTTL)
+	}
+	store := newPGStore(db, readDB, breaker)
+	h := &UserHandler{
+		pgStore:  store,
+		Users:    postgresUsers{store},
+		Cache:    c,
+		Ctx:      ctx,
+		Config:   cfg,
+		notifier: notifier,
+		l1:       l1,
 	}
+	h.ttl.Store(int64(cfg.CacheTTL))
+	return h
+}
+
+func (h *UserHandler) cacheTTL() time.Duration {
+	return time.Duration(h.ttl.Load())
+}
+
+// DetectFailover reports DB outcomes to m so stale connections are evicted
+// after a failover.
+func (h *UserHandler) DetectFailover(m *FailoverMonitor) {
+	h.failover = m
+}
+
+// SetCacheTTL changes the TTL applied to newly cached entries.
+func (h *UserHandler) SetCacheTTL(ttl time.Duration) {
+	h.ttl.Store(int64(ttl))
 }
 
 func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Prefer")
+	w.Header().Add("Vary", "Accept")
+
+	query := r.URL.Query()
+	switch format := query.Get("format"); {
+	case format == "csv", format == "" && wantsCSV(r):
+		h.exportUsersCSV(w, r)
+		return
+	case format != "" && format != "json":
+		http.Error(w, "Not Acceptable: format must be json or csv", http.StatusNotAcceptable)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 
-	cacheKey := "users:all"
-	cachedUsers, err := h.RDB.Get(h.Ctx, cacheKey).Result()
-	if err == nil {
-		w.Write([]byte(cachedUsers))
+	var fields []string
+	if query.Has("fields") {
+		var err error
+		if fields, err = parseUserFields(query.Get("fields")); err != nil {
+			http.Error(w, "Invalid fields: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if preferMinimal(r) {
+		// An explicit ?fields= wins; otherwise minimal means ids only.
+		fields = []string{"id"}
+		applyPreference(w, "return=minimal")
+	}
+
+	_, pageSize := preferPageSize(r)
+	if query.Has("after") || query.Has("limit") || pageSize {
+		h.getUsersPage(w, r, fields)
+		return
+	}
+	if fields != nil {
+		h.getUsersProjected(w, r, fields)
 		return
 	}
 
-	rows, err := h.DB.Query("SELECT id, name, email, created_at FROM users ORDER BY created_at DESC")
+	bypass := h.bypassCache(w, r)
+	usersJSON, hit, err := h.fetch(r.Context(), h.userListQuery(), !bypass)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, err)
 		return
 	}
-	defer rows.Close()
 
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	if !bypass {
+		h.setCacheHeaders(w, userListKey(), hit)
+	}
+	h.writeUsers(w, r, usersJSON)
+}
+
+// userListKey caches the full user list.
+func userListKey() string {
+	return queryKey("users:all", queryListUsers)
+}
+
+func (h *UserHandler) userListQuery() cachedQuery {
+	return cachedQuery{
+		namespace: "users:all",
+		sql:       queryListUsers,
+		run: func(ctx context.Context) (any, error) {
+			return h.Users.List(ctx)
+		},
+	}
+}
+
+// getUsersPage serves cursor-paginated listings, optionally projected to
+// fields. Pages are not cached since their contents shift as users are
+// created.
+func (h *UserHandler) getUsersPage(w http.ResponseWriter, r *http.Request, fields []string) {
+	limit, err := pageLimit(w, r)
+	if err != nil {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	query := "SELECT " + userColumns + " FROM users ORDER BY created_at DESC, id DESC LIMIT $1"
+	args := []any{limit + 1}
+	if after := r.URL.Query().Get("after"); after != "" {
+		cursor, err := decodeUserCursor(after)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
 			return
 		}
-		users = append(users, user)
+		query = "SELECT " + userColumns + ` FROM users
+			WHERE (created_at, id) < ($2::timestamp, $3)
+			ORDER BY created_at DESC, id DESC LIMIT $1`
+		args = append(args, cursor.CreatedAt.Format(cursorTimeLayout), cursor.ID)
+	}
+	if limit == 0 {
+		h.countUsersPage(w, r)
+		return
+	}
+
+	var users []models.User
+	err = h.withReader(func(db *sql.DB) error {
+		defer observeQuery(r.Context(), opSelectUsersPage, time.Now())
+		rows, err := db.QueryContext(r.Context(), query, args...)
+		if err != nil {
+			return err
+		}
+		users, err = scanUsers(rows)
+		return err
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if users == nil {
+		users = []models.User{}
 	}
 
-	usersJSON, _ := json.Marshal(users)
-	h.RDB.Set(h.Ctx, cacheKey, usersJSON, 5*time.Minute)
+	var nextCursor string
+	if len(users) > limit {
+		users = users[:limit]
+		last := users[limit-1]
+		nextCursor = userCursor{CreatedAt: last.CreatedAt, ID: last.ID}.encode()
+	}
+
+	var data any = users
+	if fields != nil {
+		data = projectUsers(users, fields)
+	}
+
+	if h.useEnvelope(r) {
+		writeEnvelope(w, data, models.Meta{Count: len(users), NextCursor: nextCursor})
+		return
+	}
+	writeJSON(w, http.StatusOK, models.UserPage{Users: data, NextCursor: nextCursor})
+}
 
-	json.NewEncoder(w).Encode(users)
+// countUsersPage answers limit=0 with an empty page and the total number of
+// users, regardless of the cursor, in X-Total-Count.
+func (h *UserHandler) countUsersPage(w http.ResponseWriter, r *http.Request) {
+	total, err := h.Users.Count(r.Context())
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	w.Header().Set(totalCountHeader, strconv.Itoa(total))
+	if h.useEnvelope(r) {
+		writeEnvelope(w, []models.User{}, models.Meta{})
+		return
+	}
+	writeJSON(w, http.StatusOK, models.UserPage{Users: []models.User{}})
 }
 
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req models.CreateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
 
-	var user models.User
-	err := h.DB.QueryRow(
-		"INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id, created_at",
-		req.Name, req.Email).Scan(&user.ID, &user.CreatedAt)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if errs := h.prepareCreate(&req); errs != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, models.ValidationResponse{Errors: errs})
+		return
+	}
+
+	if h.quotaExceeded(r.Context(), w, 1) {
 		return
 	}
 
-	user.Name = req.Name
-	user.Email = req.Email
+	if r.URL.Query().Get("if_not_exists") == "true" {
+		h.createUserIfNotExists(w, r, req)
+		return
+	}
+
+	user, err := h.Users.Create(r.Context(), req.Name, req.Email)
+	if isUniqueViolation(err) {
+		http.Error(w, "Email already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	h.usersAdded(1)
 
 	// Invalidate cache
-	h.RDB.Del(h.Ctx, "users:all")
+	h.invalidateUsers()
+
+	h.notifier.Notify("user.created", user)
+
+	// The API is not versioned, so Location points at the unversioned path.
+	w.Header().Set("Location", fmt.Sprintf("/api/users/%d", user.ID))
+	if preferMinimal(r) {
+		w.Header().Set("Preference-Applied", "return=minimal")
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	writeJSON(w, http.StatusCreated, user)
+}
 
-	json.NewEncoder(w).Encode(user)
+// defaultUserName derives a placeholder name from the local-part of email,
+// or returns "" if there is none.
+func defaultUserName(email string) string {
+	local, _, _ := strings.Cut(strings.TrimSpace(email), "@")
+	return local
+}
+
+// maxUserID is the largest id the SERIAL users.id column can hold.
+const maxUserID = math.MaxInt32
+
+// userIDParam parses the {id} path value, answering 400 for anything but a
+// positive id users.id can hold so such requests never reach the cache or DB.
+func userIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 || id > maxUserID {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return int(id), true
 }
 
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Extract ID from URL path using Go 1.22+ PathValue
-	idStr := r.PathValue("id")
-	if idStr == "" {
-		http.Error(w, "Missing user ID", http.StatusBadRequest)
+	id, ok := userIDParam(w, r)
+	if !ok {
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
+	bypass := h.bypassCache(w, r)
+	if !bypass {
+		if cachedUser, ok := h.l1Get(id); ok {
+			if h.Config.CacheHeaders {
+				w.Header().Set("X-Cache", "HIT")
+			}
+			h.writeUser(w, r, cachedUser)
+			return
+		}
+	}
+
+	userJSON, hit, err := h.fetch(r.Context(), h.userQuery(id), !bypass)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		if err == sql.ErrNoRows {
+			if bypass {
+				h.invalidateUsers(id)
+			}
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			writeDBError(w, err)
+		}
+		return
+	}
+
+	h.l1Add(id, userJSON)
+	if !bypass {
+		h.setCacheHeaders(w, userKey(id), hit)
+	}
+	h.writeUser(w, r, userJSON)
+}
+
+// userKey caches one user by id.
+func userKey(id int) string {
+	return queryKey("user", queryGetUser, id)
+}
+
+// userQuery reads one user, returning sql.ErrNoRows when it does not exist.
+func (h *UserHandler) userQuery(id int) cachedQuery {
+	return cachedQuery{
+		namespace: "user",
+		sql:       queryGetUser,
+		args:      []any{id},
+		run: func(ctx context.Context) (any, error) {
+			return h.Users.Get(ctx, id)
+		},
+		missing: func() { h.invalidateUsers(id) },
+	}
+}
+
+// timeseriesIntervals whitelists the date_trunc units accepted by
+// GetUserTimeseries.
+var timeseriesIntervals = map[string]bool{
+	"minute": true,
+	"hour":   true,
+	"day":    true,
+}
+
+func (h *UserHandler) GetUserTimeseries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "hour"
+	}
+	if !timeseriesIntervals[interval] {
+		http.Error(w, "Invalid interval: must be one of minute, hour, day", http.StatusBadRequest)
 		return
 	}
 
-	cacheKey := fmt.Sprintf("user:%d", id)
-	cachedUser, err := h.RDB.Get(h.Ctx, cacheKey).Result()
+	cacheKey := "users:timeseries:" + interval
+	cachedBuckets, _, err := h.cacheGet(r.Context(), cacheKey)
 	if err == nil {
-		w.Write([]byte(cachedUser))
+		w.Write(cachedBuckets)
 		return
 	}
 
-	var user models.User
-	err = h.DB.QueryRow("SELECT id, name, email, created_at FROM users WHERE id = $1", id).
-		Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "User not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	buckets := []models.TimeBucket{}
+	err = h.withReader(func(db *sql.DB) error {
+		defer observeQuery(r.Context(), opSelectUserTimeseries, time.Now())
+		rows, err := db.QueryContext(r.Context(),
+			"SELECT date_trunc($1, created_at) AS bucket, COUNT(*) FROM users GROUP BY bucket ORDER BY bucket",
+			interval)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		buckets = buckets[:0]
+		for rows.Next() {
+			var bucket models.TimeBucket
+			if err := rows.Scan(&bucket.Bucket, &bucket.Count); err != nil {
+				return err
+			}
+			buckets = append(buckets, bucket)
 		}
+		return rows.Err()
+	})
+	if err != nil {
+		writeDBError(w, err)
 		return
 	}
 
-	userJSON, _ := json.Marshal(user)
-	h.RDB.Set(h.Ctx, cacheKey, userJSON, 5*time.Minute)
+	bucketsJSON, err := h.cacheJSON(cacheKey, buckets, 30*time.Second)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
 
-	json.NewEncoder(w).Encode(user)
+	w.Write(bucketsJSON)
 }
\ No newline at end of file
@@ -0,0 +1,941 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-autoscale-webapp/cache"
+	"k8s-autoscale-webapp/config"
+	"k8s-autoscale-webapp/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+)
+
+var testCreatedAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// newTestUserHandler wires a UserHandler to sqlmock and miniredis. Queries
+// are matched exactly against the constants in statements.go.
+func newTestUserHandler(t *testing.T) (*UserHandler, sqlmock.Sqlmock, *miniredis.Miniredis) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	h := NewUserHandler(db, nil, cache.NewRedis(rdb), context.Background(), config.APIConfig{CacheTTL: time.Minute}, nil, nil)
+	return h, mock, mr
+}
+
+func userRows(users ...models.User) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "created_at", "version"})
+	for _, u := range users {
+		rows.AddRow(u.ID, u.Name, u.Email, u.CreatedAt, u.Version)
+	}
+	return rows
+}
+
+func testUser(id int) models.User {
+	return models.User{ID: id, Name: "Ada", Email: "ada@example.com", CreatedAt: testCreatedAt, Version: 1}
+}
+
+func expectMet(t *testing.T, mock sqlmock.Sqlmock) {
+	t.Helper()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetUsersCacheHit(t *testing.T) {
+	h, mock, mr := newTestUserHandler(t)
+	cached := `[{"id":1,"name":"Cached"}]`
+	mr.Set(userListKey(), cached)
+
+	rec := httptest.NewRecorder()
+	h.GetUsers(rec, httptest.NewRequest("GET", "/api/users", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Body.String(); got != cached {
+		t.Errorf("body = %q, want cached payload %q", got, cached)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUsersCacheMiss(t *testing.T) {
+	h, mock, mr := newTestUserHandler(t)
+	mock.ExpectPrepare(queryListUsers).ExpectQuery().WillReturnRows(userRows(testUser(1), testUser(2)))
+
+	rec := httptest.NewRecorder()
+	h.GetUsers(rec, httptest.NewRequest("GET", "/api/users", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var users []models.User
+	if err := json.Unmarshal(rec.Body.Bytes(), &users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].ID != 1 || users[1].ID != 2 {
+		t.Errorf("users = %+v, want ids 1 and 2", users)
+	}
+	if !mr.Exists(userListKey()) {
+		t.Error("users:all was not cached after a miss")
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUsersDBError(t *testing.T) {
+	h, mock, mr := newTestUserHandler(t)
+	mock.ExpectPrepare(queryListUsers).ExpectQuery().WillReturnError(errors.New("connection refused"))
+
+	rec := httptest.NewRecorder()
+	h.GetUsers(rec, httptest.NewRequest("GET", "/api/users", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if mr.Exists(userListKey()) {
+		t.Error("users:all was cached despite a DB error")
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUsersStatementTimeout(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	mock.ExpectPrepare(queryListUsers).ExpectQuery().WillReturnError(&pq.Error{Code: "57014"})
+
+	rec := httptest.NewRecorder()
+	h.GetUsers(rec, httptest.NewRequest("GET", "/api/users", nil))
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUsersCSV(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	formula := testUser(2)
+	formula.Name = "=SUM(A1)"
+	mock.ExpectPrepare(queryListUsers).ExpectQuery().WillReturnRows(userRows(testUser(1), formula))
+
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	h.GetUsers(rec, req)
+
+	if got := rec.Header().Get("Content-Disposition"); got != "attachment; filename=users.csv" {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	want := "id,name,email,created_at,version\n" +
+		"1,Ada,ada@example.com,2024-01-02T03:04:05Z,1\n" +
+		"2,'=SUM(A1),ada@example.com,2024-01-02T03:04:05Z,1\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUsersUnsupportedFormat(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+
+	rec := httptest.NewRecorder()
+	h.GetUsers(rec, httptest.NewRequest("GET", "/api/users?format=xml", nil))
+
+	if rec.Code != http.StatusNotAcceptable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotAcceptable)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUsersLimitZero(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	h.Users = fakeUsers{users: map[int]models.User{1: testUser(1), 2: testUser(2)}}
+
+	rec := httptest.NewRecorder()
+	h.GetUsers(rec, httptest.NewRequest("GET", "/api/users?limit=0", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if got := rec.Header().Get(totalCountHeader); got != "2" {
+		t.Errorf("%s = %q, want 2", totalCountHeader, got)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"users":[]}` {
+		t.Errorf("body = %s, want an empty page", got)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUsersInvalidLimit(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+
+	for _, limit := range []string{"-1", "abc"} {
+		rec := httptest.NewRecorder()
+		h.GetUsers(rec, httptest.NewRequest("GET", "/api/users?limit="+limit, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("limit %q: status = %d, want %d", limit, rec.Code, http.StatusBadRequest)
+		}
+	}
+	expectMet(t, mock)
+}
+
+func getUser(h *UserHandler, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/api/users/"+id, nil)
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	h.GetUser(rec, req)
+	return rec
+}
+
+func TestGetUserFound(t *testing.T) {
+	h, mock, mr := newTestUserHandler(t)
+	mock.ExpectPrepare(queryGetUser).ExpectQuery().WithArgs(7).WillReturnRows(userRows(testUser(7)))
+
+	rec := getUser(h, "7")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var user models.User
+	if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 7 || user.Email != "ada@example.com" {
+		t.Errorf("user = %+v, want id 7", user)
+	}
+	if !mr.Exists(userKey(7)) {
+		t.Error("user:7 was not cached")
+	}
+
+	// A second request is served from Redis without touching the DB.
+	if rec := getUser(h, "7"); rec.Code != http.StatusOK {
+		t.Errorf("cached status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUserNoCache(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	h.Cache = cache.NoCache{}
+	stmt := mock.ExpectPrepare(queryGetUser)
+	stmt.ExpectQuery().WithArgs(7).WillReturnRows(userRows(testUser(7)))
+	stmt.ExpectQuery().WithArgs(7).WillReturnRows(userRows(testUser(7)))
+
+	// Without a cache both requests read the database.
+	for i := 0; i < 2; i++ {
+		if rec := getUser(h, "7"); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUserUnprepared(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	h.DisablePreparedStatements()
+	mock.ExpectQuery(queryGetUser).WithArgs(7).WillReturnRows(userRows(testUser(7)))
+
+	if rec := getUser(h, "7"); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUserPrepareHonorsDeadline(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	mock.ExpectPrepare(queryGetUser).WillDelayFor(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "/api/users/7", nil).WithContext(ctx)
+	req.SetPathValue("id", "7")
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h.GetUser(rec, req)
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("GetUser took %s, want it cut off by the request deadline", elapsed)
+	}
+	if rec.Code == http.StatusOK {
+		t.Errorf("status = %d, want an error", rec.Code)
+	}
+}
+
+func TestGetUserNotFound(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	mock.ExpectPrepare(queryGetUser).ExpectQuery().WithArgs(9).WillReturnError(sql.ErrNoRows)
+
+	if rec := getUser(h, "9"); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUserBadID(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+
+	for _, id := range []string{"abc", "", "0", "-5", "1.5", "2147483648", "99999999999999999999"} {
+		if rec := getUser(h, id); rec.Code != http.StatusBadRequest {
+			t.Errorf("id %q: status = %d, want %d", id, rec.Code, http.StatusBadRequest)
+		}
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUserMaxID(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	mock.ExpectPrepare(queryGetUser).ExpectQuery().WithArgs(maxUserID).WillReturnError(sql.ErrNoRows)
+
+	if rec := getUser(h, "2147483647"); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUserServerTiming(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	mock.ExpectPrepare(queryGetUser).ExpectQuery().WithArgs(7).WillReturnRows(userRows(testUser(7)))
+	handler := ServerTimingMiddleware(http.HandlerFunc(h.GetUser))
+
+	get := func() string {
+		req := httptest.NewRequest("GET", "/api/users/7", nil)
+		req.SetPathValue("id", "7")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Header().Get("Server-Timing")
+	}
+
+	// A miss checks Redis, then queries the DB.
+	timing := get()
+	for _, prefix := range []string{"cache;dur=", "db;dur=", "total;dur="} {
+		if !strings.Contains(timing, prefix) {
+			t.Errorf("miss Server-Timing = %q, want %s", timing, prefix)
+		}
+	}
+	// A hit never reaches the DB.
+	if timing := get(); strings.Contains(timing, "db;") || !strings.Contains(timing, "total;dur=") {
+		t.Errorf("hit Server-Timing = %q, want cache and total only", timing)
+	}
+	expectMet(t, mock)
+}
+
+func getUserPosts(h *UserHandler, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/api/users/7/posts?"+query, nil)
+	req.SetPathValue("id", "7")
+	rec := httptest.NewRecorder()
+	h.GetUserPosts(rec, req)
+	return rec
+}
+
+func decodePosts(t *testing.T, rec *httptest.ResponseRecorder) models.UserPosts {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var posts models.UserPosts
+	if err := json.Unmarshal(rec.Body.Bytes(), &posts); err != nil {
+		t.Fatal(err)
+	}
+	if posts.Posts == nil {
+		t.Error("posts is null, want a list")
+	}
+	return posts
+}
+
+func TestGetUserPostsOffsetWithoutLimit(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	u := testUser(7)
+	mock.ExpectQuery(queryUserPosts).WithArgs(7, defaultPageLimit, 40).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "email", "created_at", "version", "id", "title", "body", "created_at"}).
+			AddRow(u.ID, u.Name, u.Email, u.CreatedAt, u.Version, 41, "Hello", "World", testCreatedAt))
+
+	posts := decodePosts(t, getUserPosts(h, "offset=40"))
+	if posts.Limit != defaultPageLimit || posts.Offset != 40 || len(posts.Posts) != 1 {
+		t.Errorf("posts = %+v, want one post at offset 40 with the default limit", posts)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUserPostsOffsetPastEnd(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	mock.ExpectQuery(queryUserPosts).WithArgs(7, 10, 1000).WillReturnRows(sqlmock.NewRows(nil))
+	mock.ExpectPrepare(queryGetUser).ExpectQuery().WithArgs(7).WillReturnRows(userRows(testUser(7)))
+
+	posts := decodePosts(t, getUserPosts(h, "limit=10&offset=1000"))
+	if len(posts.Posts) != 0 || posts.Offset != 1000 || posts.User.ID != 7 {
+		t.Errorf("posts = %+v, want an empty page for user 7", posts)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUserPostsLimitZero(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	u := testUser(7)
+	mock.ExpectQuery(queryCountUserPosts).WithArgs(7).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "email", "created_at", "version", "count"}).
+			AddRow(u.ID, u.Name, u.Email, u.CreatedAt, u.Version, 3))
+
+	rec := getUserPosts(h, "limit=0&offset=5")
+	posts := decodePosts(t, rec)
+	if got := rec.Header().Get(totalCountHeader); got != "3" {
+		t.Errorf("%s = %q, want 3", totalCountHeader, got)
+	}
+	if len(posts.Posts) != 0 || posts.Limit != 0 || posts.User.ID != 7 {
+		t.Errorf("posts = %+v, want no posts for user 7", posts)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUserPostsLimitZeroUnknownUser(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	mock.ExpectQuery(queryCountUserPosts).WithArgs(7).WillReturnRows(sqlmock.NewRows(nil))
+
+	if rec := getUserPosts(h, "limit=0"); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	expectMet(t, mock)
+}
+
+func TestGetUserPostsInvalidPage(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+
+	for _, query := range []string{"limit=-1", "limit=x", "offset=-1", "limit=0&offset=x"} {
+		if rec := getUserPosts(h, query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+	expectMet(t, mock)
+}
+
+func TestUserIDRoutesRejectBadID(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	routes := map[string]http.HandlerFunc{
+		"update":  h.UpdateUser,
+		"posts":   h.GetUserPosts,
+		"refresh": h.RefreshUserCache,
+	}
+
+	for name, handle := range routes {
+		req := httptest.NewRequest("PUT", "/api/users/-1", strings.NewReader(`{}`))
+		req.SetPathValue("id", "-1")
+		rec := httptest.NewRecorder()
+		handle(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", name, rec.Code, http.StatusBadRequest)
+		}
+	}
+	expectMet(t, mock)
+}
+
+func createUser(h *UserHandler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.CreateUser(rec, httptest.NewRequest("POST", "/api/users", strings.NewReader(body)))
+	return rec
+}
+
+func TestCreateUserSuccess(t *testing.T) {
+	h, mock, mr := newTestUserHandler(t)
+	mr.Set(userListKey(), "[]")
+	mr.Set("users:all:fields=id", "[]")
+	mr.SAdd(userListsKey, "users:all:fields=id")
+
+	mock.ExpectPrepare(queryInsertUser).ExpectQuery().
+		WithArgs("Ada", "ada@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version"}).AddRow(3, testCreatedAt, 1))
+
+	rec := createUser(h, `{"name":"Ada","email":"ada@example.com"}`)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if got := rec.Header().Get("Location"); got != "/api/users/3" {
+		t.Errorf("Location = %q, want %q", got, "/api/users/3")
+	}
+	var user models.User
+	if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 3 || user.Name != "Ada" || user.Version != 1 {
+		t.Errorf("user = %+v, want id 3 named Ada", user)
+	}
+	for _, key := range []string{userListKey(), "users:all:fields=id", userListsKey} {
+		if mr.Exists(key) {
+			t.Errorf("%s was not invalidated", key)
+		}
+	}
+	expectMet(t, mock)
+}
+
+func TestCreateUserDecodeError(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+
+	if rec := createUser(h, `{"name":`); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	expectMet(t, mock)
+}
+
+func TestCreateUserQuotaExceeded(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	h.Config.MaxUsers = 2
+	mock.ExpectQuery("SELECT COUNT(*) FROM users").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	// The count is cached, so the second attempt does not query again.
+	for range 2 {
+		if rec := createUser(h, `{"name":"Ada","email":"ada@example.com"}`); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+		}
+	}
+	expectMet(t, mock)
+}
+
+func TestCreateUserDuplicate(t *testing.T) {
+	h, mock, mr := newTestUserHandler(t)
+	mr.Set(userListKey(), "[]")
+
+	mock.ExpectPrepare(queryInsertUser).ExpectQuery().
+		WithArgs("Ada", "ada@example.com").
+		WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"})
+
+	rec := createUser(h, `{"name":"Ada","email":"ada@example.com"}`)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if strings.Contains(rec.Body.String(), "pq:") {
+		t.Errorf("body = %q, want no driver error", rec.Body)
+	}
+	if !mr.Exists(userListKey()) {
+		t.Error("users:all was invalidated by a failed insert")
+	}
+	expectMet(t, mock)
+}
+
+func updateUser(h *UserHandler, id, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PUT", "/api/users/"+id, strings.NewReader(body))
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	h.UpdateUser(rec, req)
+	return rec
+}
+
+func TestUpdateUserChangesEmail(t *testing.T) {
+	h, mock, mr := newTestUserHandler(t)
+	for _, key := range []string{userListKey(), userKey(3), userEmailKey("old@example.com"), userEmailKey("new@example.com")} {
+		mr.Set(key, "{}")
+	}
+
+	updated := models.User{ID: 3, Name: "Ada", Email: "new@example.com", CreatedAt: testCreatedAt, Version: 2}
+	mock.ExpectQuery(queryUpdateUser).
+		WithArgs("Ada", "new@example.com", 3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "created_at", "version", "email"}).
+			AddRow(updated.ID, updated.Name, updated.Email, updated.CreatedAt, updated.Version, "old@example.com"))
+
+	rec := updateUser(h, "3", `{"name":"Ada","email":"new@example.com","version":1}`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	for _, key := range []string{userListKey(), userKey(3), userEmailKey("old@example.com"), userEmailKey("new@example.com")} {
+		if mr.Exists(key) {
+			t.Errorf("%s was not invalidated", key)
+		}
+	}
+	expectMet(t, mock)
+}
+
+func TestUpdateUserEmailConflict(t *testing.T) {
+	h, mock, mr := newTestUserHandler(t)
+	mr.Set(userKey(3), "{}")
+
+	mock.ExpectQuery(queryUpdateUser).
+		WithArgs("Ada", "taken@example.com", 3, 1).
+		WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"})
+
+	rec := updateUser(h, "3", `{"name":"Ada","email":"taken@example.com","version":1}`)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if !mr.Exists(userKey(3)) {
+		t.Error("user:3 was invalidated by a rejected update")
+	}
+	expectMet(t, mock)
+}
+
+func TestCacheJSONUnencodable(t *testing.T) {
+	h, _, mr := newTestUserHandler(t)
+
+	if _, err := h.cacheJSON(userListKey(), make(chan int), time.Minute); err == nil {
+		t.Fatal("cacheJSON of a channel returned no error")
+	}
+	if mr.Exists(userListKey()) {
+		t.Error("an unencodable value was cached")
+	}
+}
+
+func TestWriteJSONUnencodable(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSON(rec, http.StatusCreated, map[string]any{"value": make(chan int)})
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(rec.Body.String(), "value") {
+		t.Errorf("body contains a partial encoding: %q", rec.Body)
+	}
+}
+
+func patchUser(h *UserHandler, id, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PATCH", "/api/users/"+id, strings.NewReader(body))
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	h.UpdateUser(rec, req)
+	return rec
+}
+
+func TestPatchUserOmittedFieldUnchanged(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+
+	updated := models.User{ID: 3, Name: "Grace", Email: "ada@example.com", CreatedAt: testCreatedAt, Version: 2}
+	mock.ExpectQuery(queryUpdateUser).
+		WithArgs("Grace", nil, 3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "created_at", "version", "email"}).
+			AddRow(updated.ID, updated.Name, updated.Email, updated.CreatedAt, updated.Version, updated.Email))
+
+	rec := patchUser(h, "3", `{"name":"Grace","version":1}`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	expectMet(t, mock)
+}
+
+func TestPatchUserNullRejected(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+
+	rec := patchUser(h, "3", `{"email":null,"version":1}`)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	var body models.ValidationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Errors["email"] == "" {
+		t.Errorf("errors = %v, want an email error", body.Errors)
+	}
+	expectMet(t, mock)
+}
+
+func TestPatchUserNullClears(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	h.Config.UpdateNullClears = true
+
+	updated := models.User{ID: 3, Name: "", Email: "ada@example.com", CreatedAt: testCreatedAt, Version: 2}
+	mock.ExpectQuery(queryUpdateUser).
+		WithArgs("", nil, 3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "created_at", "version", "email"}).
+			AddRow(updated.ID, updated.Name, updated.Email, updated.CreatedAt, updated.Version, updated.Email))
+
+	rec := patchUser(h, "3", `{"name":null,"version":1}`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	expectMet(t, mock)
+}
+
+func TestPatchUserValueSet(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+
+	updated := models.User{ID: 3, Name: "Ada", Email: "new@example.com", CreatedAt: testCreatedAt, Version: 2}
+	mock.ExpectQuery(queryUpdateUser).
+		WithArgs(nil, "new@example.com", 3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "created_at", "version", "email"}).
+			AddRow(updated.ID, updated.Name, updated.Email, updated.CreatedAt, updated.Version, "ada@example.com"))
+
+	rec := patchUser(h, "3", `{"email":"new@example.com","version":1}`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var user models.User
+	if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "new@example.com" {
+		t.Errorf("email = %q, want new@example.com", user.Email)
+	}
+	expectMet(t, mock)
+}
+
+func upsertUsers(h *UserHandler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.UpsertUsers(rec, httptest.NewRequest("POST", "/api/users/upsert", strings.NewReader(body)))
+	return rec
+}
+
+func TestUpsertUsersRetriesDeadlock(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare(queryUpsertUser).ExpectQuery().
+		WithArgs("Ada", "ada@example.com").
+		WillReturnError(&pq.Error{Code: "40P01", Message: "deadlock detected"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectPrepare(queryUpsertUser).ExpectQuery().
+		WithArgs("Ada", "ada@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "inserted"}).AddRow(3, testCreatedAt, 1, true))
+	mock.ExpectCommit()
+
+	rec := upsertUsers(h, `[{"name":"Ada","email":"ada@example.com"}]`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var response models.UpsertResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Inserted != 1 || len(response.Users) != 1 || response.Users[0].ID != 3 {
+		t.Errorf("response = %+v, want one inserted user with id 3", response)
+	}
+	expectMet(t, mock)
+}
+
+func TestUpsertUsersInvalidatesEmails(t *testing.T) {
+	h, mock, mr := newTestUserHandler(t)
+	keys := []string{userKey(3), userEmailKey("ada@example.com")}
+	for _, key := range keys {
+		mr.Set(key, "{}")
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare(queryUpsertUser).ExpectQuery().
+		WithArgs("Ada L.", "ada@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "inserted"}).AddRow(3, testCreatedAt, 2, false))
+	mock.ExpectCommit()
+
+	rec := upsertUsers(h, `[{"name":"Ada L.","email":"ada@example.com"}]`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	for _, key := range keys {
+		if mr.Exists(key) {
+			t.Errorf("%s was not invalidated", key)
+		}
+	}
+	expectMet(t, mock)
+}
+
+func TestUpsertUsersConflictExhausted(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+
+	for i := 0; i < maxTxAttempts; i++ {
+		mock.ExpectBegin()
+		mock.ExpectPrepare(queryUpsertUser).ExpectQuery().
+			WithArgs("Ada", "ada@example.com").
+			WillReturnError(&pq.Error{Code: "40001", Message: "could not serialize access"})
+		mock.ExpectRollback()
+	}
+
+	rec := upsertUsers(h, `[{"name":"Ada","email":"ada@example.com"}]`)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After not set on a conflict 503")
+	}
+	expectMet(t, mock)
+}
+
+// fakeUsers is an in-memory UserRepository.
+type fakeUsers struct {
+	users map[int]models.User
+}
+
+func (f fakeUsers) List(context.Context) ([]models.User, error) {
+	users := make([]models.User, 0, len(f.users))
+	for _, user := range f.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (f fakeUsers) Get(_ context.Context, id int) (models.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return models.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (f fakeUsers) Create(_ context.Context, name, email string) (models.User, error) {
+	user := models.User{ID: len(f.users) + 1, Name: name, Email: email, CreatedAt: testCreatedAt, Version: 1}
+	f.users[user.ID] = user
+	return user, nil
+}
+
+func (f fakeUsers) Update(_ context.Context, id, version int, req models.UpdateUserRequest) (models.User, string, error) {
+	user, ok := f.users[id]
+	switch {
+	case !ok:
+		return models.User{}, "", sql.ErrNoRows
+	case user.Version != version:
+		return models.User{}, "", errVersionConflict
+	}
+	oldEmail := user.Email
+	if req.Name.Set {
+		user.Name = req.Name.Value
+	}
+	if req.Email.Set {
+		user.Email = req.Email.Value
+	}
+	user.Version++
+	f.users[id] = user
+	return user, oldEmail, nil
+}
+
+func (f fakeUsers) Delete(_ context.Context, id int) error {
+	if _, ok := f.users[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(f.users, id)
+	return nil
+}
+
+func (f fakeUsers) Count(context.Context) (int, error) {
+	return len(f.users), nil
+}
+
+func TestUpdateUserVersionConflict(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	user := testUser(3)
+	user.Version = 2
+	h.Users = fakeUsers{users: map[int]models.User{3: user}}
+
+	rec := updateUser(h, "3", `{"name":"Ada","email":"ada@example.com","version":1}`)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body)
+	}
+	if rec := updateUser(h, "4", `{"name":"Ada","email":"ada@example.com","version":1}`); rec.Code != http.StatusNotFound {
+		t.Errorf("missing user status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	expectMet(t, mock)
+}
+
+func TestApplyUserChangeSkipsOwnWrites(t *testing.T) {
+	h, _, mr := newTestUserHandler(t)
+	mr.Set(userKey(7), "cached")
+
+	h.applyUserChange(`{"id": 7, "emails": ["ada@example.com"], "origin": "pod-a"}`, "pod-a")
+	if !mr.Exists(userKey(7)) {
+		t.Error("own change invalidated user:7")
+	}
+
+	h.applyUserChange(`{"id": 7, "emails": ["ada@example.com"], "origin": "psql"}`, "pod-a")
+	if mr.Exists(userKey(7)) {
+		t.Error("external change left user:7 cached")
+	}
+}
+
+func TestUpsertUsersQuota(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+	h.Config.MaxUsers = 10
+	mock.ExpectQuery("SELECT COUNT(*) FROM users").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(9))
+
+	body := `[{"name":"Ada","email":"ada@example.com"},{"name":"Bob","email":"bob@example.com"}]`
+	rec := httptest.NewRecorder()
+	h.UpsertUsers(rec, httptest.NewRequest("POST", "/api/users/upsert", strings.NewReader(body)))
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"error":"user quota exceeded"}` {
+		t.Errorf("body = %s", got)
+	}
+	expectMet(t, mock)
+}
+
+func TestRefreshUserCacheEmailChanged(t *testing.T) {
+	h, mock, mr := newTestUserHandler(t)
+	stale := testUser(3)
+	stale.Email = "old@example.com"
+	payload, _ := json.Marshal(stale)
+	mr.Set(userKey(3), string(payload))
+	mr.Set(userEmailKey("old@example.com"), "{}")
+	mr.Set(userEmailKey(testUser(3).Email), "{}")
+
+	mock.ExpectPrepare(queryGetUser).ExpectQuery().WithArgs(3).WillReturnRows(userRows(testUser(3)))
+
+	req := httptest.NewRequest("POST", "/api/users/3/refresh-cache", nil)
+	req.SetPathValue("id", "3")
+	rec := httptest.NewRecorder()
+	h.RefreshUserCache(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	for _, email := range []string{"old@example.com", testUser(3).Email} {
+		if mr.Exists(userEmailKey(email)) {
+			t.Errorf("by-email entry for %s was not invalidated", email)
+		}
+	}
+	if !mr.Exists(userKey(3)) {
+		t.Error("user:3 was not re-cached")
+	}
+	expectMet(t, mock)
+}
+
+func TestServerTimingWithoutBody(t *testing.T) {
+	handler := ServerTimingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("DELETE", "/api/users/7", nil))
+
+	if timing := rec.Header().Get("Server-Timing"); !strings.HasPrefix(timing, "total;dur=") {
+		t.Errorf("Server-Timing = %q, want total", timing)
+	}
+}
+
+func TestUpsertUsersValidation(t *testing.T) {
+	h, mock, _ := newTestUserHandler(t)
+
+	rec := upsertUsers(h, `[{"name":"Ada","email":"ada@example.com"},{"name":"","email":"not-an-email"}]`)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusUnprocessableEntity, rec.Body)
+	}
+	var response models.ValidationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Errors) != 2 || response.Errors["[1].name"] == "" || response.Errors["[1].email"] == "" {
+		t.Errorf("errors = %v, want [1].name and [1].email", response.Errors)
+	}
+	expectMet(t, mock)
+}
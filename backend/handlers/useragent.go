@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// UserAgentMiddleware rejects requests with an empty User-Agent or one
+// containing a blocklisted substring with 403. Agents matching the allowlist
+// (known load-test tools) are always let through. Matching is
+// case-insensitive.
+func UserAgentMiddleware(allow, block []string) func(http.Handler) http.Handler {
+	allow = lowerAll(allow)
+	block = lowerAll(block)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			agent := strings.ToLower(r.UserAgent())
+			if containsAny(agent, allow) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if agent == "" || containsAny(agent, block) {
+				log.Printf("Rejected request %s %s from %s: user agent %q", r.Method, r.URL.Path, remoteIP(r), r.UserAgent())
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			lowered = append(lowered, v)
+		}
+	}
+	return lowered
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// prepareCreate fills in a default name when enabled and validates req as
+// CreateUser would. A provided name always wins; one is generated only when
+// it is blank.
+func (h *UserHandler) prepareCreate(req *models.CreateUserRequest) models.FieldErrors {
+	if strings.TrimSpace(req.Name) == "" && h.Config.DefaultUserName {
+		req.Name = defaultUserName(req.Email)
+	}
+	return req.Validate()
+}
+
+// ValidateUser checks a create-user payload without inserting it, answering
+// 200 {"valid":true} or 422 with field errors. With ?check_email=true it also
+// reports an email that is already taken.
+func (h *UserHandler) ValidateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	errs := h.prepareCreate(&req)
+	if errs["email"] == "" && r.URL.Query().Get("check_email") == "true" {
+		var taken bool
+		err := h.withReader(func(db *sql.DB) error {
+			defer observeQuery(r.Context(), opSelectUserExists, time.Now())
+			return db.QueryRowContext(r.Context(), "SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)", req.Email).Scan(&taken)
+		})
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		if taken {
+			if errs == nil {
+				errs = models.FieldErrors{}
+			}
+			errs["email"] = "is already taken"
+		}
+	}
+
+	if errs != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, models.ValidationResponse{Errors: errs})
+		return
+	}
+	writeJSON(w, http.StatusOK, models.ValidationResponse{Valid: true})
+}
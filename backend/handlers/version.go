@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"k8s-autoscale-webapp/models"
+)
+
+// VersionHandler reports the build and the deployment environment, so
+// responses from several environments running the same image can be told
+// apart.
+type VersionHandler struct {
+	response models.VersionResponse
+}
+
+func NewVersionHandler(environment string) *VersionHandler {
+	response := models.VersionResponse{Revision: "unknown", GoVersion: runtime.Version(), Environment: environment}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				response.Revision = setting.Value
+			}
+		}
+	}
+	return &VersionHandler{response: response}
+}
+
+func (h *VersionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	writeJSON(w, http.StatusOK, h.response)
+}
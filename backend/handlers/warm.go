@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"k8s-autoscale-webapp/cache"
+	"k8s-autoscale-webapp/lock"
+)
+
+const (
+	warmLockKey = "lock:cache-warm"
+	warmLockTTL = 30 * time.Second
+)
+
+// WarmCache preloads the full user list into Redis. Only one pod warms at a
+// time: the others skip when the Redis lock is already held.
+func (h *UserHandler) WarmCache(ctx context.Context) {
+	release, err := h.Cache.Lock(ctx, warmLockKey, warmLockTTL)
+	if errors.Is(err, cache.ErrDisabled) {
+		return
+	}
+	if errors.Is(err, lock.ErrNotAcquired) {
+		log.Println("Cache warm skipped: another instance holds the lock")
+		return
+	}
+	if err != nil {
+		log.Printf("Cache warm skipped: %v", err)
+		return
+	}
+	defer release(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, warmLockTTL)
+	defer cancel()
+
+	start := time.Now()
+	rows, err := h.queryPrepared(ctx, h.ReadDB, queryListUsers)
+	if err != nil {
+		log.Printf("Cache warm failed: %v", err)
+		return
+	}
+	users, err := scanUsers(rows)
+	observeQuery(ctx, opSelectUsers, start)
+	if err != nil {
+		log.Printf("Cache warm failed: %v", err)
+		return
+	}
+
+	if _, err := h.cacheJSON(userListKey(), users, h.cacheTTL()); err != nil {
+		return
+	}
+	log.Printf("Cache warmed with %d users", len(users))
+}
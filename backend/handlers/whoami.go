@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"k8s-autoscale-webapp/config"
+	"k8s-autoscale-webapp/models"
+)
+
+// Whoami reports which pod served a request, making load balancing and
+// scaling visible during demos.
+type Whoami struct {
+	pod     config.PodConfig
+	started time.Time
+}
+
+func NewWhoami(pod config.PodConfig) *Whoami {
+	return &Whoami{pod: pod, started: time.Now()}
+}
+
+// ServeHTTP handles GET /whoami.
+func (h *Whoami) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, models.Whoami{
+		Pod:       h.pod.Name,
+		Node:      h.pod.Node,
+		PodIP:     h.pod.IP,
+		StartedAt: h.started,
+		Uptime:    time.Since(h.started).Round(time.Second).String(),
+	})
+}
+
+// Middleware sets X-Served-By to the pod name on every response.
+func (h *Whoami) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.pod.Name != "" {
+			w.Header().Set("X-Served-By", h.pod.Name)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
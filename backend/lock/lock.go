@@ -0,0 +1,53 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrNotAcquired is returned by Acquire when another holder owns the lock.
+var ErrNotAcquired = errors.New("lock not acquired")
+
+// releaseScript deletes the key only if it still holds our token, so an
+// expired holder cannot release a lock since taken by someone else.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Lock is a single-instance Redis lock taken with SET NX PX.
+type Lock struct {
+	rdb   *redis.Client
+	key   string
+	token string
+}
+
+// Acquire takes key for ttl. The lock expires on its own if the holder dies
+// without releasing it.
+func Acquire(ctx context.Context, rdb *redis.Client, key string, ttl time.Duration) (*Lock, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(buf)
+
+	ok, err := rdb.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+	return &Lock{rdb: rdb, key: key, token: token}, nil
+}
+
+// Release frees the lock if it is still held by this token.
+func (l *Lock) Release(ctx context.Context) error {
+	return releaseScript.Run(ctx, l.rdb, []string{l.key}, l.token).Err()
+}
@@ -2,54 +2,200 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
+	"k8s-autoscale-webapp/auth"
+	"k8s-autoscale-webapp/cache"
 	"k8s-autoscale-webapp/config"
 	"k8s-autoscale-webapp/handlers"
+	"k8s-autoscale-webapp/metrics"
+	"k8s-autoscale-webapp/settings"
+	"k8s-autoscale-webapp/webhook"
 
 	"github.com/go-redis/redis/v8"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 func main() {
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	// Unlike slog's built-in default, a handler of our own also receives
+	// log.Printf output, so every log line carries the environment.
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)).With("environment", cfg.Environment))
+	metrics.Register(cfg.Environment)
+	slog.Info("Effective configuration", "config", cfg)
+	if cfg.ServerConfig.CgroupGOMAXPROCS && os.Getenv("GOMAXPROCS") == "" {
+		if procs := handlers.CgroupGOMAXPROCS(); procs > 0 && procs != runtime.GOMAXPROCS(0) {
+			log.Printf("GOMAXPROCS set to %d from the container CPU limit (was %d)", procs, runtime.GOMAXPROCS(procs))
+		}
+	}
 	ctx := context.Background()
 
+	// Cleanup steps run after the server stops, last registered first
+	hooks := newShutdownHooks(cfg.ServerConfig.ShutdownHookTimeout)
+
 	// Initialize database
 	db, err := initDB(cfg.DatabaseConfig)
-	if err != nil {
+	if errors.Is(err, errUnsupportedSchema) {
+		log.Fatal("Incompatible database schema: ", err)
+	} else if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
-	defer db.Close()
+	hooks.addCloser("database", db.Close)
 
-	// Initialize Redis
-	rdb, err := initRedis(cfg.RedisConfig, ctx)
+	detectedSchema, err := readSchemaVersion(db)
 	if err != nil {
-		log.Printf("Redis connection failed: %v", err)
-	} else {
-		log.Println("Redis connected successfully")
-		defer rdb.Close()
+		log.Printf("Schema version unknown: %v", err)
+	}
+
+	// Initialize read replica, falling back to the primary when unset or unreachable
+	readDB := initReadDB(cfg.ReadDatabaseConfig)
+	if readDB != nil {
+		hooks.addCloser("read replica", readDB.Close)
+	}
+
+	// Initialize Redis; without a host the cache is disabled
+	var store cache.Cache = cache.NoCache{}
+	switch {
+	case cfg.RedisConfig.Host == "" && cfg.RedisConfig.Required:
+		log.Fatal("REDIS_HOST is not set and REDIS_REQUIRED=true")
+	case cfg.RedisConfig.Host == "":
+		log.Println("REDIS_HOST is not set: caching disabled, serving all reads from the database")
+	default:
+		rdb, err := initRedis(cfg.RedisConfig, ctx)
+		if err != nil {
+			log.Printf("Redis connection failed: %v", err)
+		} else {
+			log.Println("Redis connected successfully")
+		}
+		redisCache := cache.NewRedis(rdb)
+		redisCache.LogSlow(cfg.RedisConfig.SlowThreshold)
+		hooks.addCloser("redis", redisCache.Close)
+		store = redisCache
 	}
 
 	// Initialize handlers
-	healthHandler := handlers.NewHealthHandler(db, rdb, ctx)
-	userHandler := handlers.NewUserHandler(db, rdb, ctx)
-	stressHandler := handlers.NewStressHandler()
+	healthHandler := handlers.NewHealthHandler(db, store, ctx, cfg.ServerConfig.HealthCacheTTL, cfg.Environment, cfg.ServerConfig.RequiredDeps)
+	healthHandler.DegradedLatency = cfg.ServerConfig.HealthDegradedLatency
+	healthHandler.SchemaVersion = detectedSchema
+	readinessHandler := handlers.NewReadinessHandler()
+	readinessHandler.CheckDependencies(healthHandler)
+	if cfg.ServerConfig.ReadinessPoolCheck {
+		readinessHandler.CheckPool(db, cfg.ServerConfig.ReadinessPoolWaitThreshold)
+	}
+	notifier := webhook.NewNotifier(cfg.WebhookConfig)
+	// Hooks also run when Shutdown times out, so handlers may still call
+	// Notify after this; the notifier drops such events rather than panic.
+	hooks.add("webhook queue", notifier.Close)
+
+	userHandler := handlers.NewUserHandler(db, readDB, store, ctx, cfg.APIConfig,
+		handlers.NewDBBreaker(cfg.BreakerConfig), notifier)
+	stressHandler := handlers.NewStressHandler(handlers.StressMemoryCeiling(cfg.StressConfig.MemoryFraction, cfg.StressConfig.MemoryMaxMB), db)
+	stressHandler.ProgressInterval = cfg.StressConfig.ProgressInterval
+	stressHandler.DBQueryTimeout = cfg.StressConfig.DBQueryTimeout
+	stressQueue := handlers.NewStressQueue(cfg.StressConfig.AsyncWorkers, cfg.StressConfig.AsyncQueueSize)
+	hooks.addFunc("stress queue", stressQueue.Close)
+	maintenance := handlers.NewMaintenance(cfg.ServerConfig.MaintenanceMode, cfg.ServerConfig.MaintenanceRetryAfter)
+
+	failover := handlers.NewFailoverMonitor(cfg.DatabaseConfig.MaxIdleConns, db, readDB)
+	userHandler.DetectFailover(failover)
+	healthHandler.DetectFailover(failover)
+
+	if cfg.DatabaseConfig.PreparedStatements {
+		userHandler.PrepareStatements(ctx)
+	} else {
+		userHandler.DisablePreparedStatements()
+	}
+	hooks.addFunc("prepared statements", userHandler.Close)
+
+	go userHandler.SubscribeInvalidations(ctx)
+	if cfg.APIConfig.CacheListenNotify {
+		if err := ensureUsersChangedTrigger(db); err != nil {
+			log.Printf("Users change notifications disabled, trigger could not be created: %v", err)
+		} else {
+			go userHandler.ListenForChanges(ctx, cfg.DatabaseConfig.ConnectionString(), cfg.DatabaseConfig.ApplicationName)
+		}
+	}
+
+	// Runtime-tunable parameters, overridable via /api/admin/settings
+	limiter := handlers.NewRateLimiter(cfg.RateLimitConfig.RequestsPerSecond, cfg.RateLimitConfig.Burst)
+	settingsStore := settings.NewStore(db)
+	settingsStore.Register("cache_ttl", cfg.APIConfig.CacheTTL.String(), settings.PositiveDuration, func(v string) {
+		ttl, _ := time.ParseDuration(v)
+		userHandler.SetCacheTTL(ttl)
+	})
+	settingsStore.Register("rate_limit_rps", strconv.FormatFloat(cfg.RateLimitConfig.RequestsPerSecond, 'g', -1, 64), settings.NonNegativeFloat, func(v string) {
+		rate, _ := strconv.ParseFloat(v, 64)
+		limiter.SetRate(rate)
+	})
+	settingsStore.Register("rate_limit_burst", strconv.Itoa(cfg.RateLimitConfig.Burst), settings.NonNegativeInt, func(v string) {
+		burst, _ := strconv.Atoi(v)
+		limiter.SetBurst(burst)
+	})
+	if err := settingsStore.Refresh(ctx); err != nil {
+		log.Printf("Failed to load settings, using environment values: %v", err)
+	}
+	go settingsStore.Run(ctx, cfg.ServerConfig.SettingsRefreshInterval)
+	settingsHandler := handlers.NewSettingsHandler(settingsStore)
+
+	if cfg.APIConfig.CacheWarm {
+		go userHandler.WarmCache(ctx)
+	}
+
+	// Open pooled connections before serving so a freshly scaled pod's first
+	// requests don't each pay for a new connection
+	warmPool("database", db, cfg.DatabaseConfig)
+	if readDB != nil {
+		warmPool("read replica", readDB, cfg.ReadDatabaseConfig)
+	}
 
 	// Create a new ServeMux
 	mux := http.NewServeMux()
+	routes := newRoutes(mux, cfg.ServerConfig.DisabledEndpoints)
 
 	// Health check endpoint
-	mux.Handle("GET /health", healthHandler)
-	mux.Handle("GET /api/health", healthHandler)
+	routes.handle("health", "GET /health", healthHandler)
+	routes.handle("health", "GET /api/health", healthHandler)
+	routes.handle("health", "GET /healthz", healthHandler)
+	routes.handle("readiness", "GET /readyz", readinessHandler)
+	routes.handle("version", "GET /api/version", handlers.NewVersionHandler(cfg.Environment))
+
+	verifier := auth.NewVerifier(cfg.ServerConfig.JWTSecret)
+
+	// DB-bound and CPU-bound handlers get separate concurrency limits
+	dbLimiter := handlers.NewConcurrencyLimiter(cfg.ConcurrencyConfig.DBRequests)
+	stressLimiter := handlers.NewConcurrencyLimiter(cfg.ConcurrencyConfig.StressRequests)
+	dbBound := func(h http.HandlerFunc) http.Handler { return dbLimiter.Limit(h) }
 
 	// User endpoints using Go 1.22+ pattern matching
-	mux.HandleFunc("GET /api/users", userHandler.GetUsers)
-	mux.HandleFunc("POST /api/users", userHandler.CreateUser)
-	mux.HandleFunc("GET /api/users/{id}", userHandler.GetUser)
+	routes.handle("users.list", "GET /api/users", dbBound(userHandler.GetUsers))
+	routes.handle("users.create", "POST /api/users", dbBound(userHandler.CreateUser))
+	routes.handle("users.get", "GET /api/users/{id}", dbBound(userHandler.GetUser))
+	routes.handle("users.batch_get", "POST /api/users/batch-get", dbBound(userHandler.BatchGetUsers))
+	routes.handle("users.validate", "POST /api/users/validate", dbBound(userHandler.ValidateUser))
+	routes.handle("users.me", "GET /api/users/me", auth.Require(verifier, dbBound(userHandler.GetMe)))
+	routes.handle("users.update", "PUT /api/users/{id}", dbBound(userHandler.UpdateUser))
+	routes.handle("users.update", "PATCH /api/users/{id}", dbBound(userHandler.UpdateUser))
+	routes.handle("users.upsert", "POST /api/users/upsert", dbBound(userHandler.UpsertUsers))
+	routes.handle("users.posts", "GET /api/users/{id}/posts", dbBound(userHandler.GetUserPosts))
+	routes.handle("users.timeseries", "GET /api/users/stats/timeseries", dbBound(userHandler.GetUserTimeseries))
 	mux.HandleFunc("OPTIONS /api/users", func(w http.ResponseWriter, r *http.Request) {
 		// CORS preflight handled by middleware
 	})
@@ -57,17 +203,181 @@ func main() {
 		// CORS preflight handled by middleware
 	})
 
+	whoami := handlers.NewWhoami(cfg.PodConfig)
+	routes.handle("whoami", "GET /whoami", whoami)
+
+	// Prometheus metrics
+	routes.handle("metrics", "GET /metrics", metrics.Handler())
+
 	// Stress test endpoint
-	mux.Handle("GET /api/stress", stressHandler)
+	routes.handle("stress", "GET /api/stress", stressLimiter.Limit(stressHandler))
 	mux.HandleFunc("OPTIONS /api/stress", func(w http.ResponseWriter, r *http.Request) {
 		// CORS preflight handled by middleware
 	})
+	routes.handle("stress.mixed", "POST /api/stress/mixed", stressLimiter.Limit(http.HandlerFunc(stressHandler.Mixed)))
+	routes.handleFunc("stress.async", "POST /api/stress/async", stressQueue.Submit)
+	routes.handleFunc("stress.jobs", "GET /api/stress/jobs", stressQueue.List)
+	routes.handleFunc("stress.jobs", "GET /api/stress/jobs/{id}", stressQueue.Status)
+	routes.handleFunc("stress.jobs", "DELETE /api/stress/jobs/{id}", stressQueue.Cancel)
+
+	// Admin endpoints
+	adminAllowlist, err := handlers.AdminAllowlist(cfg.ServerConfig.AdminAllowedCIDRs)
+	if err != nil {
+		log.Fatal("Invalid ADMIN_ALLOWED_CIDRS:", err)
+	}
+	requireAdmin := func(h http.Handler) http.Handler {
+		return adminAllowlist(handlers.RequireAdmin(cfg.ServerConfig.AdminToken, h))
+	}
+	routes.handle("admin.maintenance", "GET /api/admin/maintenance", requireAdmin(maintenance))
+	routes.handle("admin.maintenance", "POST /api/admin/maintenance", requireAdmin(maintenance))
+	routes.handle("users.refresh_cache", "POST /api/users/{id}/refresh-cache", requireAdmin(dbBound(userHandler.RefreshUserCache)))
+	routes.handle("admin.settings", "GET /api/admin/settings", requireAdmin(http.HandlerFunc(settingsHandler.List)))
+	routes.handle("admin.settings", "PUT /api/admin/settings/{name}", requireAdmin(http.HandlerFunc(settingsHandler.Update)))
+	routes.handle("admin.settings", "DELETE /api/admin/settings/{name}", requireAdmin(http.HandlerFunc(settingsHandler.Reset)))
+	concurrencyAdmin := handlers.NewConcurrencyAdmin(map[string]*handlers.ConcurrencyLimiter{
+		"db":     dbLimiter,
+		"stress": stressLimiter,
+	})
+	routes.handle("admin.concurrency", "GET /api/admin/concurrency", requireAdmin(concurrencyAdmin))
+	routes.handle("admin.concurrency", "POST /api/admin/concurrency", requireAdmin(concurrencyAdmin))
+	routes.handle("debug.runtime", "GET /debug/runtime", requireAdmin(http.HandlerFunc(handlers.RuntimeStats)))
+	routes.handle("debug.cpu", "GET /debug/cpu", requireAdmin(http.HandlerFunc(handlers.CPUStats)))
+	var capture *handlers.BodyCapture
+	if cc := cfg.CaptureConfig; cc.Enabled {
+		capture = handlers.NewBodyCapture(cc.Size, cc.MaxBodyBytes, cc.SampleRate)
+		routes.handle("debug.requests", "GET /debug/requests", requireAdmin(capture))
+	}
+
+	// Fault injection for chaos tests, only when explicitly enabled
+	var delayInjector *handlers.DelayInjector
+	var errorInjector *handlers.ErrorInjector
+	if cfg.ChaosConfig.Enabled {
+		delayInjector = handlers.NewDelayInjector(cfg.ChaosConfig.DelayMin, cfg.ChaosConfig.DelayMax)
+		routes.handle("admin.chaos", "GET /api/admin/chaos/delay", requireAdmin(delayInjector))
+		routes.handle("admin.chaos", "POST /api/admin/chaos/delay", requireAdmin(delayInjector))
+
+		errorInjector = handlers.NewErrorInjector(cfg.ChaosConfig.ErrorRate, cfg.ChaosConfig.ErrorRoutes)
+		routes.handle("admin.chaos", "GET /api/admin/chaos/errors", requireAdmin(errorInjector))
+		routes.handle("admin.chaos", "POST /api/admin/chaos/errors", requireAdmin(errorInjector))
+	}
+
+	// Workload replay against the local handlers, for test environments
+	if rc := cfg.ReplayConfig; rc.Enabled {
+		routes.handle("admin.replay", "POST /api/admin/replay", requireAdmin(handlers.NewReplayer(mux, rc.MaxRequests, rc.MaxConcurrency)))
+	}
+
+	routes.logSummary()
+
+	// Wrap with middleware, innermost first
+	var handler http.Handler = handlers.RouteSizeMiddleware(mux)
+	if cfg.ChaosConfig.Enabled {
+		handler = delayInjector.Middleware(handler)
+		handler = errorInjector.Middleware(handler)
+	}
+	if cfg.APIConfig.JSONNaming == "camel" {
+		handler = handlers.JSONNamingMiddleware(handler)
+	}
+	handler = handlers.TrailingSlashMiddleware(cfg.ServerConfig.TrailingSlashMode)(handler)
+	handler = handlers.RequestTimeoutMiddleware(cfg.ServerConfig.MaxRequestTimeout)(handler)
+	handler = maintenance.Middleware(handler)
+	handler = handlers.JSONOnlyMiddleware(handler)
+	handler = limiter.Middleware(handler)
+	if cfg.ServerConfig.ShedTargetLatency > 0 {
+		handler = handlers.NewLoadShedder(cfg.ServerConfig.ShedTargetLatency, cfg.ServerConfig.ShedMaxRate).Middleware(handler)
+	}
+	if cfg.ServerConfig.UserAgentFilter {
+		handler = handlers.UserAgentMiddleware(cfg.ServerConfig.UserAgentAllowlist, cfg.ServerConfig.UserAgentBlocklist)(handler)
+	}
+	handler = handlers.URLLengthMiddleware(cfg.ServerConfig.MaxURLLength, cfg.ServerConfig.MaxQueryParamLength)(handler)
+	handler = handlers.BodyLimitMiddleware(cfg.ServerConfig.MaxRequestBodyBytes)(handler)
+	handler = handlers.CORSMiddleware(handler)
+	clientIP, err := handlers.ClientIPMiddleware(cfg.ServerConfig.TrustedProxies)
+	if err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+	if capture != nil {
+		handler = capture.Middleware(handler)
+	}
+	if al := cfg.AccessLogConfig; al.Enabled {
+		handler = handlers.NewAccessLog(al.SampleThreshold, al.SampleRate, al.SlowThreshold).Middleware(handler)
+	}
+	handler = clientIP(handler)
+	if cfg.ServerConfig.JSONErrors {
+		handler = handlers.JSONErrorMiddleware(handler)
+	}
+	if cfg.ServerConfig.ServerTiming {
+		handler = handlers.ServerTimingMiddleware(handler)
+	}
+	handler = handlers.RequestIDMiddleware(handler)
+	handler = whoami.Middleware(handler)
+	handler = handlers.InFlightMiddleware(handler)
+
+	server := &http.Server{
+		Addr:              ":" + cfg.ServerConfig.Port,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ServerConfig.ReadHeaderTimeout,
+		IdleTimeout:       cfg.ServerConfig.IdleTimeout,
+		ConnState:         trackConnState,
+	}
+	server.SetKeepAlivesEnabled(cfg.ServerConfig.HTTPKeepAlives)
+
+	// Listen explicitly so TCP keepalive on accepted connections is configurable
+	listenConfig := net.ListenConfig{KeepAlive: cfg.ServerConfig.TCPKeepAlive}
+	listener, err := listenConfig.Listen(ctx, "tcp", server.Addr)
+	if err != nil {
+		log.Fatal("Failed to listen:", err)
+	}
+
+	go func() {
+		log.Printf("Server starting on port %s...", cfg.ServerConfig.Port)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Server failed:", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+
+	// SIGTERM comes from Kubernetes: stop advertising readiness and keep
+	// serving while the pod is removed from the service endpoints, then drain
+	// in-flight requests. SIGINT is a local Ctrl-C and skips the delay.
+	readinessHandler.SetReady(false)
+	shutdownTimeout := cfg.ServerConfig.ShutdownTimeout
+	if sig == syscall.SIGTERM {
+		log.Printf("Shutdown requested by %s, draining for %s...", sig, cfg.ServerConfig.ShutdownDrainDelay)
+		select {
+		case <-time.After(cfg.ServerConfig.ShutdownDrainDelay):
+		case sig = <-quit:
+			log.Printf("Received %s during drain delay, shutting down now", sig)
+		}
+	} else {
+		shutdownTimeout = cfg.ServerConfig.InterruptShutdownTimeout
+		log.Printf("Shutdown requested by %s, stopping within %s", sig, shutdownTimeout)
+	}
+
+	inFlight := handlers.InFlightRequests()
+	slog.Info("Shutdown drain started", "signal", sig.String(), "in_flight", inFlight, "timeout", shutdownTimeout)
 
-	// Wrap with CORS middleware
-	handler := handlers.CORSMiddleware(mux)
+	drainStart := time.Now()
+	shutdownCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Graceful shutdown failed: %v", err)
+	}
+	drained := time.Since(drainStart)
+	slog.Info("Shutdown drain finished", "duration", drained, "abandoned", handlers.InFlightRequests())
+	hooks.run(ctx)
+	log.Println("Server stopped")
+}
 
-	log.Printf("Server starting on port %s...", cfg.ServerConfig.Port)
-	log.Fatal(http.ListenAndServe(":"+cfg.ServerConfig.Port, handler))
+func trackConnState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		metrics.OpenConnections.Inc()
+	case http.StateClosed, http.StateHijacked:
+		metrics.OpenConnections.Dec()
+	}
 }
 
 func initDB(cfg config.DatabaseConfig) (*sql.DB, error) {
@@ -75,12 +385,21 @@ func initDB(cfg config.DatabaseConfig) (*sql.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	configurePool(db, cfg)
 
 	if err = db.Ping(); err != nil {
 		log.Printf("Database connection failed: %v", err)
 		return db, nil // Return db anyway for health checks
 	}
 
+	// Refuse (or warn about) a schema this binary was not built for before
+	// touching it
+	if err = checkSchemaVersion(db, cfg.SchemaCheckStrict); errors.Is(err, errUnsupportedSchema) {
+		return nil, err
+	} else if err != nil {
+		log.Printf("Schema version check skipped: %v", err)
+	}
+
 	// Create users table if not exists
 	createTableQuery := `
 	CREATE TABLE IF NOT EXISTS users (
@@ -90,21 +409,209 @@ func initDB(cfg config.DatabaseConfig) (*sql.DB, error) {
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	)`
 
-	_, err = db.Exec(createTableQuery)
+	if err = ensureSchema(db, createTableQuery, tableExists("users")); err != nil {
+		return nil, err
+	}
+
+	// Add the optimistic concurrency version column to existing tables
+	err = ensureSchema(db, `ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1`,
+		`SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'version')`)
 	if err != nil {
 		return nil, err
 	}
 
+	// Create posts table used by the joined user posts endpoint
+	createPostsQuery := `
+	CREATE TABLE IF NOT EXISTS posts (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		title VARCHAR(200),
+		body TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	if err = ensureSchema(db, createPostsQuery, tableExists("posts")); err != nil {
+		return nil, err
+	}
+	err = ensureSchema(db, `CREATE INDEX IF NOT EXISTS posts_user_id_created_at_idx ON posts (user_id, created_at DESC)`,
+		tableExists("posts_user_id_created_at_idx"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Create settings table holding runtime overrides of tunable parameters
+	err = ensureSchema(db, `
+	CREATE TABLE IF NOT EXISTS settings (
+		name VARCHAR(100) PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`, tableExists("settings"))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = recordSchemaVersion(db); err != nil {
+		return nil, err
+	}
+
 	log.Println("Database initialized successfully")
 	return db, nil
 }
 
+// ensureUsersChangedTrigger makes the users table NOTIFY users_changed on
+// every row change so pods can invalidate caches for writes they did not
+// make themselves. The payload names the writer's application_name, which
+// lets a pod skip its own writes. Only pods listening for changes create
+// it; an advisory lock serializes pods starting together.
+func ensureUsersChangedTrigger(db *sql.DB) error {
+	return ensureSchema(db, `
+	SELECT pg_advisory_xact_lock(hashtext('users_changed'));
+
+	CREATE OR REPLACE FUNCTION notify_users_changed() RETURNS trigger AS $$
+	DECLARE
+		changed users%ROWTYPE;
+		emails TEXT[];
+	BEGIN
+		IF TG_OP = 'DELETE' THEN
+			changed := OLD;
+		ELSE
+			changed := NEW;
+		END IF;
+		emails := ARRAY[changed.email];
+		IF TG_OP = 'UPDATE' AND OLD.email IS DISTINCT FROM NEW.email THEN
+			emails := emails || OLD.email;
+		END IF;
+		PERFORM pg_notify('users_changed', json_build_object(
+			'id', changed.id,
+			'emails', emails,
+			'origin', current_setting('application_name', true))::text);
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE TRIGGER users_changed AFTER INSERT OR UPDATE OR DELETE ON users
+		FOR EACH ROW EXECUTE FUNCTION notify_users_changed();
+	`, `SELECT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'users_changed')`)
+}
+
+// ensureSchema runs a DDL statement. When the DB user lacks privileges for it
+// (e.g. migrations are applied separately with another role) and existsQuery
+// confirms the object is already there, it logs a warning and carries on;
+// any other failure, or a missing object, is returned.
+func ensureSchema(db *sql.DB, ddl, existsQuery string) error {
+	_, err := db.Exec(ddl)
+	if err == nil {
+		return nil
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "42501" {
+		return err
+	}
+
+	var exists bool
+	if checkErr := db.QueryRow(existsQuery).Scan(&exists); checkErr != nil || !exists {
+		return err
+	}
+	log.Printf("Warning: skipping schema change without privileges, object already exists: %v", err)
+	return nil
+}
+
+// tableExists returns a query reporting whether a table or index exists.
+func tableExists(name string) string {
+	return fmt.Sprintf("SELECT to_regclass('%s') IS NOT NULL", name)
+}
+
+func initReadDB(cfg config.DatabaseConfig) *sql.DB {
+	if cfg.Host == "" {
+		return nil
+	}
+
+	db, err := sql.Open("postgres", cfg.ConnectionString())
+	if err != nil {
+		log.Printf("Read replica configuration invalid, using primary: %v", err)
+		return nil
+	}
+	configurePool(db, cfg)
+
+	if err = db.Ping(); err != nil {
+		log.Printf("Read replica connection failed, using primary: %v", err)
+		db.Close()
+		return nil
+	}
+
+	log.Printf("Read replica connected at %s", cfg.Host)
+	return db
+}
+
+func configurePool(db *sql.DB, cfg config.DatabaseConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+}
+
+// warmPool opens and pings up to cfg.WarmupConns connections concurrently,
+// then returns them to the pool as idle connections. It is best-effort:
+// failures are logged and startup continues.
+func warmPool(name string, db *sql.DB, cfg config.DatabaseConfig) {
+	n := min(cfg.WarmupConns, cfg.MaxIdleConns)
+	if cfg.MaxOpenConns > 0 {
+		n = min(n, cfg.MaxOpenConns)
+	}
+	if n <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.WarmupTimeout)
+	defer cancel()
+
+	start := time.Now()
+	conns := make([]*sql.Conn, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := db.Conn(ctx)
+			if err == nil {
+				err = conn.PingContext(ctx)
+			}
+			conns[i], errs[i] = conn, err
+		}()
+	}
+	wg.Wait()
+
+	warmed := 0
+	var firstErr error
+	for i, conn := range conns {
+		if conn != nil {
+			conn.Close()
+		}
+		if errs[i] == nil {
+			warmed++
+		} else if firstErr == nil {
+			firstErr = errs[i]
+		}
+	}
+	if firstErr != nil {
+		log.Printf("Warmed %d/%d %s connections in %v: %v", warmed, n, name, time.Since(start), firstErr)
+		return
+	}
+	log.Printf("Warmed %d %s connections in %v", warmed, name, time.Since(start))
+}
+
 func initRedis(cfg config.RedisConfig, ctx context.Context) (*redis.Client, error) {
-	rdb := redis.NewClient(&redis.Options{
+	opts := &redis.Options{
 		Addr:     cfg.Address(),
+		Username: cfg.Username,
 		Password: cfg.Password,
 		DB:       cfg.DB,
-	})
+	}
+	if cfg.TLS {
+		opts.TLSConfig = &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
+	}
+	rdb := redis.NewClient(opts)
 
 	_, err := rdb.Ping(ctx).Result()
 	return rdb, err
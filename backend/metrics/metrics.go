@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DBBreakerState mirrors the database circuit breaker:
+// 0 = closed, 1 = half-open, 2 = open.
+var DBBreakerState = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "db_circuit_breaker_state",
+	Help: "State of the database circuit breaker (0=closed, 1=half-open, 2=open).",
+})
+
+// OpenConnections counts client connections currently held by the server.
+var OpenConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "http_open_connections",
+	Help: "Number of open client connections.",
+})
+
+// InFlightRequests counts requests currently being served.
+var InFlightRequests = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "http_in_flight_requests",
+	Help: "Number of requests currently being served.",
+})
+
+// sizeBuckets span 64 B to 1 MiB.
+var sizeBuckets = prometheus.ExponentialBuckets(64, 4, 8)
+
+// RequestSize and ResponseSize record body sizes by mux route pattern, e.g.
+// "GET /api/users/{id}", or "unmatched".
+var RequestSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "http_request_size_bytes",
+	Help:    "Size of request bodies by route.",
+	Buckets: sizeBuckets,
+}, []string{"route"})
+
+var ResponseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "http_response_size_bytes",
+	Help:    "Size of response bodies by route.",
+	Buckets: sizeBuckets,
+}, []string{"route"})
+
+// LoadShedRate is the fraction of requests the load shedder is currently
+// rejecting with 503.
+var LoadShedRate = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "load_shed_rate",
+	Help: "Fraction of requests currently shed to keep latency on target.",
+})
+
+// StressAbandoned counts synchronous stress runs stopped early because the
+// client disconnected or its deadline passed, by mode ("cpu" or "memory").
+var StressAbandoned = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "stress_abandoned_total",
+	Help: "Number of stress runs stopped early after the request was cancelled.",
+}, []string{"mode"})
+
+// StressQueueDepth is the number of async stress jobs waiting for a worker.
+var StressQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "stress_queue_depth",
+	Help: "Number of queued async stress jobs.",
+})
+
+// DBQueryDuration times user-handler queries by operation, e.g. select_user
+// or insert_user.
+var DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "db_query_duration_seconds",
+	Help:    "Duration of database queries by operation.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"operation"})
+
+// DBTxRetries counts transactions retried after a deadlock or serialization
+// failure, by operation.
+var DBTxRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "db_transaction_retries_total",
+	Help: "Number of transactions retried after a deadlock or serialization failure.",
+}, []string{"operation"})
+
+// ChaosInjectedErrors counts 500s returned by error injection, by the
+// configured route prefix that matched.
+var ChaosInjectedErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "chaos_injected_errors_total",
+	Help: "Number of responses failed on purpose by error injection.",
+}, []string{"route"})
+
+// CachePayloadBytes records the size of each payload written to the user
+// caches: "user_list" for the users:all namespace, "user" for single users.
+var CachePayloadBytes = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+	Name:       "cache_payload_bytes",
+	Help:       "Size in bytes of payloads written to the user caches.",
+	Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+}, []string{"cache"})
+
+// CacheOOMErrors counts cache writes Redis rejected for exceeding maxmemory.
+var CacheOOMErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "cache_oom_errors_total",
+	Help: "Number of cache writes rejected by Redis as out of memory.",
+})
+
+// CacheHits, CacheMisses and CacheErrors count cache lookups across all
+// keys by outcome: found, absent, or failed because Redis could not be
+// reached. Reads fall back to the database on both misses and errors.
+var CacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "cache_hits_total",
+	Help: "Number of cache lookups that found the key.",
+})
+
+var CacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "cache_misses_total",
+	Help: "Number of cache lookups for keys that were not cached.",
+})
+
+var CacheErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "cache_errors_total",
+	Help: "Number of cache lookups that failed with a Redis error.",
+})
+
+// CacheSlowOperations counts Redis commands slower than the configured
+// threshold, by operation (e.g. "get", "set" or "pipeline").
+var CacheSlowOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_slow_operations_total",
+	Help: "Number of cache operations slower than the slow threshold.",
+}, []string{"operation"})
+
+// WebhookQueueDepth is the number of webhook events waiting for a worker.
+var WebhookQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "webhook_queue_depth",
+	Help: "Number of queued webhook events.",
+})
+
+// WebhookDeliveries counts webhook events by outcome: "success",
+// "failure" (dead-lettered after retries) or "dropped" (queue full).
+var WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "webhook_deliveries_total",
+	Help: "Number of webhook events by delivery outcome.",
+}, []string{"result"})
+
+var registry = prometheus.NewRegistry()
+
+// Register adds all collectors to the registry served by Handler, labeling
+// every series with the deployment environment so several environments can
+// share one monitoring stack. It must be called once at startup.
+func Register(environment string) {
+	reg := prometheus.WrapRegistererWith(prometheus.Labels{"environment": environment}, registry)
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		DBBreakerState,
+		OpenConnections,
+		InFlightRequests,
+		LoadShedRate,
+		RequestSize,
+		ResponseSize,
+		StressQueueDepth,
+		StressAbandoned,
+		DBQueryDuration,
+		DBTxRetries,
+		ChaosInjectedErrors,
+		CachePayloadBytes,
+		CacheOOMErrors,
+		CacheHits,
+		CacheMisses,
+		CacheErrors,
+		CacheSlowOperations,
+		WebhookQueueDepth,
+		WebhookDeliveries,
+	)
+}
+
+// Handler serves all registered metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
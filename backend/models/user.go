@@ -1,12 +1,48 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
 	ID        int       `json:"id"`
 	Name      string    `json:"name"`
 	Email     string    `json:"email"`
 	CreatedAt time.Time `json:"created_at"`
+	// Version increments on every update and guards against lost updates.
+	Version int `json:"version"`
+}
+
+// UserPage holds either []User or a field projection of them.
+type UserPage struct {
+	Users      any    `json:"users"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type Meta struct {
+	Count      int    `json:"count"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type Envelope struct {
+	Data any  `json:"data"`
+	Meta Meta `json:"meta"`
+}
+
+type Post struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserPosts struct {
+	User   User   `json:"user"`
+	Posts  []Post `json:"posts"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
 }
 
 type CreateUserRequest struct {
@@ -14,15 +50,319 @@ type CreateUserRequest struct {
 	Email string `json:"email"`
 }
 
+type BatchGetRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// BatchGetResponse keys found users by id; ids with no user are listed in
+// NotFound.
+type BatchGetResponse struct {
+	Users    map[int]json.RawMessage `json:"users"`
+	NotFound []int                   `json:"not_found"`
+}
+
+type TimeBucket struct {
+	Bucket time.Time `json:"bucket"`
+	Count  int       `json:"count"`
+}
+
+// UpdateUserRequest is the body of PUT and PATCH /api/users/{id}. PATCH
+// leaves omitted fields unchanged; PUT requires both.
+type UpdateUserRequest struct {
+	Name    Optional[string] `json:"name"`
+	Email   Optional[string] `json:"email"`
+	Version *int             `json:"version,omitempty"`
+}
+
+// Optional is a JSON field that tells an omitted key (Set is false) apart
+// from an explicit null (Null is true) and a value.
+type Optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+type UpsertedUser struct {
+	User
+	Inserted bool `json:"inserted"`
+}
+
+type UpsertResponse struct {
+	Users    []UpsertedUser `json:"users"`
+	Inserted int            `json:"inserted"`
+	Updated  int            `json:"updated"`
+}
+
 type HealthResponse struct {
-	Status    string    `json:"status"`
-	Database  string    `json:"database"`
-	Redis     string    `json:"redis"`
-	Timestamp time.Time `json:"timestamp"`
+	// Status is "healthy", "degraded" or "unhealthy".
+	Status      string    `json:"status"`
+	Database    string    `json:"database"`
+	Redis       string    `json:"redis"`
+	Environment string    `json:"environment"`
+	Timestamp   time.Time `json:"timestamp"`
+	// Dependencies repeats the checks above with whether each one gates
+	// readiness.
+	Dependencies []DependencyStatus `json:"dependencies"`
+	// LastFailoverRecovery is when the DB last came back after its
+	// connection pools were reset, if ever.
+	LastFailoverRecovery *time.Time `json:"last_failover_recovery,omitempty"`
+	SchemaVersion        int        `json:"schema_version,omitempty"`
+}
+
+// VersionResponse identifies the running build and where it is deployed.
+type VersionResponse struct {
+	// Revision is the VCS revision the binary was built from, or "unknown"
+	// when it was built without VCS information.
+	Revision    string `json:"revision"`
+	GoVersion   string `json:"go_version"`
+	Environment string `json:"environment"`
+}
+
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Required  bool    `json:"required"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+type ReadinessResponse struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyStatus `json:"dependencies,omitempty"`
+	Pool         *PoolStats         `json:"pool,omitempty"`
+}
+
+// PoolStats summarizes the DB connection pool; WaitDelta is the number of
+// waits for a connection since the previous readiness probe.
+type PoolStats struct {
+	MaxOpen   int   `json:"max_open"`
+	Open      int   `json:"open"`
+	InUse     int   `json:"in_use"`
+	Idle      int   `json:"idle"`
+	WaitCount int64 `json:"wait_count"`
+	WaitDelta int64 `json:"wait_delta"`
+	Saturated bool  `json:"saturated"`
+}
+
+// ErrorResponse is the body of error responses when JSON errors are
+// enabled.
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type MaintenanceStatus struct {
+	Enabled bool `json:"enabled"`
 }
 
 type StressTestResponse struct {
 	Message    string `json:"message"`
-	Result     int    `json:"result"`
-	Iterations int    `json:"iterations"`
+	Mode       string `json:"mode,omitempty"`
+	Result     int    `json:"result,omitempty"`
+	Iterations int    `json:"iterations,omitempty"`
+	MemoryMB   int    `json:"memory_mb,omitempty"`
+	// Ramp describes the warm-up that preceded full load, if any.
+	Ramp *StressRamp `json:"ramp,omitempty"`
+}
+
+// StressRamp lists the busy fraction of each equal ramp step, ending just
+// below the full load that follows.
+type StressRamp struct {
+	Duration   string    `json:"duration"`
+	DutyCycles []float64 `json:"duty_cycles"`
+}
+
+// StressProgress reports a streamed CPU run. Phase is "ramp" during the
+// warm-up, with Percent of the ramp done and its current DutyCycle, or
+// "burn" afterwards, with Percent of the iterations done and Result the
+// running sum so far.
+type StressProgress struct {
+	Phase      string  `json:"phase"`
+	Percent    float64 `json:"percent"`
+	Elapsed    string  `json:"elapsed"`
+	DutyCycle  float64 `json:"duty_cycle,omitempty"`
+	Iterations int     `json:"iterations,omitempty"`
+	Result     int     `json:"result,omitempty"`
+}
+
+type MixedStressRequest struct {
+	CPUIterations int `json:"cpu_iterations"`
+	MemoryMB      int `json:"memory_mb"`
+	DBQueries     int `json:"db_queries"`
+}
+
+// StressDimension reports one kind of load in a mixed stress run. Result is
+// the CPU sum, megabytes held or queries completed; TimedOut counts DB
+// queries cut off by the stress query timeout.
+type StressDimension struct {
+	Requested int    `json:"requested"`
+	Result    int    `json:"result"`
+	TimedOut  int    `json:"timed_out,omitempty"`
+	Duration  string `json:"duration"`
+	Error     string `json:"error,omitempty"`
+}
+
+type MixedStressResponse struct {
+	Message string           `json:"message"`
+	CPU     *StressDimension `json:"cpu,omitempty"`
+	Memory  *StressDimension `json:"memory,omitempty"`
+	DB      *StressDimension `json:"db,omitempty"`
+}
+
+type StressJobList struct {
+	Jobs       []StressJob `json:"jobs"`
+	QueueDepth int         `json:"queue_depth"`
+}
+
+type ChaosDelay struct {
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+type ChaosErrors struct {
+	Rate   float64  `json:"rate"`
+	Routes []string `json:"routes"`
+}
+
+// ConcurrencyLimit reports a concurrency group's limit, 0 meaning
+// unlimited, and the requests it is currently running.
+type ConcurrencyLimit struct {
+	Group    string `json:"group"`
+	Limit    int    `json:"limit"`
+	InFlight int    `json:"in_flight"`
+}
+
+// Whoami identifies the pod that served a request.
+type Whoami struct {
+	Pod       string    `json:"pod"`
+	Node      string    `json:"node,omitempty"`
+	PodIP     string    `json:"pod_ip,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Uptime    string    `json:"uptime"`
+}
+
+type RuntimeStats struct {
+	Goroutines    int       `json:"goroutines"`
+	GOMAXPROCS    int       `json:"gomaxprocs"`
+	NumCPU        int       `json:"num_cpu"`
+	HeapAlloc     uint64    `json:"heap_alloc_bytes"`
+	HeapInuse     uint64    `json:"heap_inuse_bytes"`
+	HeapObjects   uint64    `json:"heap_objects"`
+	Sys           uint64    `json:"sys_bytes"`
+	NumGC         uint32    `json:"num_gc"`
+	GCPauseTotal  string    `json:"gc_pause_total"`
+	LastGCPause   string    `json:"last_gc_pause"`
+	LastGC        time.Time `json:"last_gc,omitzero"`
+	GCCPUFraction float64   `json:"gc_cpu_fraction"`
+}
+
+// CPUStats reports GOMAXPROCS against the container CPU limit in cores,
+// which is 0 when unlimited or unreadable.
+type CPUStats struct {
+	GOMAXPROCS int     `json:"gomaxprocs"`
+	NumCPU     int     `json:"num_cpu"`
+	CPULimit   float64 `json:"cpu_limit"`
+	Mismatch   bool    `json:"mismatch"`
+	Warning    string  `json:"warning,omitempty"`
+}
+
+const (
+	StressJobQueued    = "queued"
+	StressJobRunning   = "running"
+	StressJobCompleted = "completed"
+	StressJobCancelled = "cancelled"
+	// StressJobCancelling is only reported by the cancel response for a
+	// job that is still winding down.
+	StressJobCancelling = "cancelling"
+)
+
+type StressJob struct {
+	ID         string    `json:"id"`
+	Status     string    `json:"status"`
+	Iterations int       `json:"iterations"`
+	Result     int       `json:"result,omitempty"`
+	QueuedAt   time.Time `json:"queued_at"`
+	StartedAt  time.Time `json:"started_at,omitzero"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	// QueueDepth is the number of jobs waiting when the snapshot was taken.
+	QueueDepth int `json:"queue_depth"`
+}
+
+type Setting struct {
+	Name       string `json:"name"`
+	Value      string `json:"value"`
+	Default    string `json:"default"`
+	Overridden bool   `json:"overridden"`
+}
+
+type UpdateSettingRequest struct {
+	Value string `json:"value"`
+}
+
+// ReplayRequest describes a workload for /api/admin/replay: each step is
+// sent Repeat times, by Concurrency workers in total.
+type ReplayRequest struct {
+	Steps       []ReplayStep `json:"steps"`
+	Concurrency int          `json:"concurrency"`
+}
+
+type ReplayStep struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	Repeat  int               `json:"repeat"`
+}
+
+// ReplayResult reports a replayed workload: overall throughput plus the
+// status counts and latency percentiles of each step.
+type ReplayResult struct {
+	// Incomplete is set when the replay request ended before every call
+	// was sent; the stats cover the calls that were.
+	Incomplete        bool              `json:"incomplete,omitempty"`
+	Requests          int               `json:"requests"`
+	Duration          string            `json:"duration"`
+	RequestsPerSecond float64           `json:"requests_per_second"`
+	Steps             []ReplayStepStats `json:"steps"`
+}
+
+type ReplayStepStats struct {
+	Method   string         `json:"method"`
+	Path     string         `json:"path"`
+	Requests int            `json:"requests"`
+	Statuses map[string]int `json:"statuses"`
+	Latency  ReplayLatency  `json:"latency"`
+}
+
+type ReplayLatency struct {
+	Min string `json:"min"`
+	P50 string `json:"p50"`
+	P95 string `json:"p95"`
+	P99 string `json:"p99"`
+	Max string `json:"max"`
+}
+
+// CapturedRequest is a failing request kept by the debug body capture, with
+// secrets redacted and bodies truncated to the capture limit.
+type CapturedRequest struct {
+	Time              time.Time         `json:"time"`
+	RequestID         string            `json:"request_id,omitempty"`
+	Method            string            `json:"method"`
+	Path              string            `json:"path"`
+	Query             string            `json:"query,omitempty"`
+	Status            int               `json:"status"`
+	Duration          string            `json:"duration"`
+	Headers           map[string]string `json:"headers"`
+	RequestBody       string            `json:"request_body"`
+	RequestTruncated  bool              `json:"request_truncated,omitempty"`
+	ResponseBody      string            `json:"response_body"`
+	ResponseTruncated bool              `json:"response_truncated,omitempty"`
 }
\ No newline at end of file
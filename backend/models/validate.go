@@ -0,0 +1,102 @@
+package models
+
+import (
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+// Column limits of the users table.
+const (
+	maxNameLength  = 100
+	maxEmailLength = 100
+)
+
+// FieldErrors maps a request field to what is wrong with it.
+type FieldErrors map[string]string
+
+type ValidationResponse struct {
+	Valid  bool        `json:"valid"`
+	Errors FieldErrors `json:"errors,omitempty"`
+}
+
+// Validate checks r against the users table constraints. It returns nil
+// when r is valid.
+func (r CreateUserRequest) Validate() FieldErrors {
+	errs := FieldErrors{}
+	errs.check("name", validateName(r.Name))
+	errs.check("email", validateEmail(r.Email))
+	return errs.orNil()
+}
+
+// Validate checks the fields present in r. With partial (PATCH) omitted
+// fields are left unchanged, otherwise they are required. An explicit null
+// name clears it when nullClears is set; a null email is always rejected
+// since email identifies the user.
+func (r UpdateUserRequest) Validate(partial, nullClears bool) FieldErrors {
+	errs := FieldErrors{}
+
+	switch {
+	case !r.Name.Set:
+		if !partial {
+			errs["name"] = "is required"
+		}
+	case r.Name.Null:
+		if !nullClears {
+			errs["name"] = "must not be null"
+		}
+	default:
+		errs.check("name", validateName(r.Name.Value))
+	}
+
+	switch {
+	case !r.Email.Set:
+		if !partial {
+			errs["email"] = "is required"
+		}
+	case r.Email.Null:
+		errs["email"] = "must not be null"
+	default:
+		errs.check("email", validateEmail(r.Email.Value))
+	}
+
+	return errs.orNil()
+}
+
+func (e FieldErrors) check(field, problem string) {
+	if problem != "" {
+		e[field] = problem
+	}
+}
+
+func (e FieldErrors) orNil() FieldErrors {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// validateName and validateEmail describe what is wrong with a value, or
+// return "".
+func validateName(name string) string {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return "is required"
+	case utf8.RuneCountInString(name) > maxNameLength:
+		return "must be at most 100 characters"
+	}
+	return ""
+}
+
+func validateEmail(email string) string {
+	switch {
+	case email == "":
+		return "is required"
+	case utf8.RuneCountInString(email) > maxEmailLength:
+		return "must be at most 100 characters"
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return "is not a valid email address"
+	}
+	return ""
+}
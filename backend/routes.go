@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// routes registers handlers on a mux under endpoint names (e.g.
+// "users.list") so DISABLED_ENDPOINTS can switch them off per environment.
+// A disabled route stays registered but answers 404, so another method on
+// the same path does not turn it into a 405.
+type routes struct {
+	mux      *http.ServeMux
+	disabled map[string]bool
+	enabled  []string
+	skipped  []string
+}
+
+func newRoutes(mux *http.ServeMux, disabled []string) *routes {
+	rt := &routes{mux: mux, disabled: make(map[string]bool, len(disabled))}
+	for _, name := range disabled {
+		rt.disabled[name] = true
+	}
+	return rt
+}
+
+func (rt *routes) handle(name, pattern string, handler http.Handler) {
+	if rt.disabled[name] {
+		rt.skipped = appendName(rt.skipped, name)
+		handler = http.NotFoundHandler()
+	} else {
+		rt.enabled = appendName(rt.enabled, name)
+	}
+	rt.mux.Handle(pattern, handler)
+}
+
+func (rt *routes) handleFunc(name, pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.handle(name, pattern, http.HandlerFunc(handler))
+}
+
+// logSummary logs the enabled and disabled endpoint names, warning about
+// disabled names that match no route.
+func (rt *routes) logSummary() {
+	slog.Info("Endpoints registered", "enabled", rt.enabled, "disabled", rt.skipped)
+	for name := range rt.disabled {
+		if !slices.Contains(rt.skipped, name) {
+			log.Printf("Warning: DISABLED_ENDPOINTS names unknown endpoint %q", name)
+		}
+	}
+}
+
+func appendName(names []string, name string) []string {
+	if slices.Contains(names, name) {
+		return names
+	}
+	return append(names, name)
+}
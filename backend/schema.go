@@ -0,0 +1,90 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+// schemaVersion is the version initDB migrates the database to. Bump it
+// whenever initDB gains a schema change.
+//
+//	1 users, 2 users.version, 3 posts, 4 settings, 5 users_changed trigger
+//
+// The users_changed trigger itself is only created by pods with
+// CACHE_LISTEN_NOTIFY=true, see ensureUsersChangedTrigger.
+const schemaVersion = 5
+
+// maxSchemaVersion is the newest schema version this binary can run
+// against. A version above it means a newer release migrated the database,
+// e.g. before this pod was rolled back; older versions are migrated up.
+const maxSchemaVersion = schemaVersion
+
+var errUnsupportedSchema = errors.New("unsupported schema version")
+
+// recordSchemaVersion stores schemaVersion unless the database already
+// records a newer one, which an older binary must never downgrade.
+func recordSchemaVersion(db *sql.DB) error {
+	err := ensureSchema(db, `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		version INTEGER NOT NULL
+	)`, tableExists("schema_version"))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`INSERT INTO schema_version (id, version) VALUES (1, $1)
+		ON CONFLICT (id) DO UPDATE SET version = GREATEST(schema_version.version, EXCLUDED.version)`, schemaVersion)
+	if err == nil {
+		return nil
+	}
+	// Without write privileges, carry on only if migrations applied
+	// separately already recorded this version.
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42501" {
+		if version, readErr := readSchemaVersion(db); readErr == nil && version >= schemaVersion {
+			log.Printf("Warning: skipping schema version update without privileges, version %d already recorded", version)
+			return nil
+		}
+	}
+	return fmt.Errorf("recording schema version: %w", err)
+}
+
+// readSchemaVersion returns the recorded schema version, or 0 when none is
+// recorded yet, as in a fresh database.
+func readSchemaVersion(db *sql.DB) (int, error) {
+	var exists bool
+	if err := db.QueryRow(tableExists("schema_version")).Scan(&exists); err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	err := db.QueryRow("SELECT version FROM schema_version WHERE id = 1").Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// checkSchemaVersion runs before any DDL and verifies this binary supports
+// the database's schema version, so an old binary never migrates a newer
+// schema. An unsupported version is an errUnsupportedSchema error when
+// strict, otherwise a loud warning.
+func checkSchemaVersion(db *sql.DB, strict bool) error {
+	version, err := readSchemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	if version > maxSchemaVersion {
+		err := fmt.Errorf("%w: %d is newer than the %d supported by this binary", errUnsupportedSchema, version, maxSchemaVersion)
+		if strict {
+			return err
+		}
+		log.Printf("WARNING: %v; continuing because SCHEMA_CHECK_STRICT is off", err)
+	}
+	return nil
+}
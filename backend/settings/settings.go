@@ -0,0 +1,196 @@
+package settings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s-autoscale-webapp/models"
+)
+
+var (
+	ErrUnknownSetting = errors.New("unknown setting")
+	ErrInvalidValue   = errors.New("invalid value")
+)
+
+type setting struct {
+	fallback string
+	validate func(string) error
+	apply    func(string)
+}
+
+// Store holds runtime-tunable parameters. Overrides live in the settings
+// table; parameters without one use their env-based fallback. Only
+// registered names can be overridden.
+type Store struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	settings  map[string]setting
+	overrides map[string]string
+	applied   map[string]string
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db:        db,
+		settings:  make(map[string]setting),
+		overrides: make(map[string]string),
+		applied:   make(map[string]string),
+	}
+}
+
+// Register declares a tunable parameter. validate rejects bad overrides and
+// apply is called with the effective value whenever it changes.
+func (s *Store) Register(name, fallback string, validate func(string) error, apply func(string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[name] = setting{fallback: fallback, validate: validate, apply: apply}
+	s.applied[name] = fallback
+}
+
+// Refresh reloads overrides from the database and applies any changes.
+func (s *Store) Refresh(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT name, value FROM settings")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	overrides := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return err
+		}
+		overrides[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides = overrides
+	s.applyLocked()
+	return nil
+}
+
+// Run refreshes every interval until ctx is done, so overrides written by
+// other pods take effect here too.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				log.Printf("Settings refresh failed: %v", err)
+			}
+		}
+	}
+}
+
+// applyLocked applies effective values that differ from the last applied
+// ones. Overrides that fail validation (e.g. written by a newer version) are
+// ignored in favour of the fallback.
+func (s *Store) applyLocked() {
+	for name, st := range s.settings {
+		value := st.fallback
+		if override, ok := s.overrides[name]; ok && st.validate(override) == nil {
+			value = override
+		}
+		if s.applied[name] != value {
+			st.apply(value)
+			s.applied[name] = value
+			log.Printf("Setting %s = %s", name, value)
+		}
+	}
+}
+
+// List returns every registered parameter with its effective value.
+func (s *Store) List() []models.Setting {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]models.Setting, 0, len(s.settings))
+	for name, st := range s.settings {
+		_, overridden := s.overrides[name]
+		list = append(list, models.Setting{
+			Name:       name,
+			Value:      s.applied[name],
+			Default:    st.fallback,
+			Overridden: overridden,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Set validates and stores an override, applying it immediately.
+func (s *Store) Set(ctx context.Context, name, value string) error {
+	if err := s.check(name, value); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO settings (name, value, updated_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at`,
+		name, value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[name] = value
+	s.applyLocked()
+	return nil
+}
+
+// Reset removes an override so the env-based value applies again.
+func (s *Store) Reset(ctx context.Context, name string) error {
+	if _, err := s.lookup(name); err != nil {
+		return err
+	}
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM settings WHERE name = $1", name); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.overrides, name)
+	s.applyLocked()
+	return nil
+}
+
+func (s *Store) lookup(name string) (setting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.settings[name]
+	if !ok {
+		return st, fmt.Errorf("%w: %s", ErrUnknownSetting, name)
+	}
+	return st, nil
+}
+
+func (s *Store) check(name, value string) error {
+	st, err := s.lookup(name)
+	if err != nil {
+		return err
+	}
+	if err := st.validate(value); err != nil {
+		return fmt.Errorf("%w %q for %s: %v", ErrInvalidValue, value, name, err)
+	}
+	return nil
+}
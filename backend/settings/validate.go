@@ -0,0 +1,34 @@
+package settings
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// PositiveDuration accepts durations such as "30s" greater than zero.
+func PositiveDuration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return errors.New("must be a positive duration")
+	}
+	return nil
+}
+
+// NonNegativeFloat accepts numbers >= 0.
+func NonNegativeFloat(value string) error {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		return errors.New("must be a number >= 0")
+	}
+	return nil
+}
+
+// NonNegativeInt accepts integers >= 0.
+func NonNegativeInt(value string) error {
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return errors.New("must be an integer >= 0")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// shutdownHook is a named cleanup step run during graceful shutdown.
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// shutdownHooks collects cleanup steps from the components main starts and
+// runs them in reverse registration order, like deferred calls, each bounded
+// by timeout.
+type shutdownHooks struct {
+	timeout time.Duration
+	hooks   []shutdownHook
+}
+
+func newShutdownHooks(timeout time.Duration) *shutdownHooks {
+	return &shutdownHooks{timeout: timeout}
+}
+
+func (s *shutdownHooks) add(name string, fn func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// addCloser registers a Close-style method that takes no context.
+func (s *shutdownHooks) addCloser(name string, close func() error) {
+	s.add(name, func(context.Context) error { return close() })
+}
+
+// addFunc registers a cleanup step that cannot fail.
+func (s *shutdownHooks) addFunc(name string, fn func()) {
+	s.add(name, func(context.Context) error {
+		fn()
+		return nil
+	})
+}
+
+// run executes the hooks last-registered first. A hook that fails is
+// logged; one that outlives the timeout is abandoned so later hooks still
+// run.
+func (s *shutdownHooks) run(ctx context.Context) {
+	for i := len(s.hooks) - 1; i >= 0; i-- {
+		hook := s.hooks[i]
+		hookCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		done := make(chan error, 1)
+		go func() { done <- hook.fn(hookCtx) }()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				log.Printf("Shutdown hook %s failed: %v", hook.name, err)
+			}
+		case <-hookCtx.Done():
+			log.Printf("Shutdown hook %s did not finish within %s", hook.name, s.timeout)
+		}
+		cancel()
+	}
+}
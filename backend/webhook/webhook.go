@@ -0,0 +1,236 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s-autoscale-webapp/config"
+	"k8s-autoscale-webapp/metrics"
+)
+
+// SignatureHeader carries "sha256=<hex HMAC of the body>" keyed by the
+// configured secret, so receivers can verify the sender.
+const SignatureHeader = "X-Webhook-Signature"
+
+type delivery struct {
+	event string
+	body  []byte
+}
+
+// Retry backoff starts at initialBackoff and doubles per attempt up to
+// maxBackoff.
+const (
+	initialBackoff = 250 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+)
+
+// Notifier posts events to a webhook URL from background workers. Notify
+// never blocks the caller: when the bounded queue is full the event is
+// dropped and logged. Failed posts are retried with exponential backoff up
+// to MaxAttempts; deliveries that still fail are written to the dead-letter
+// log.
+type Notifier struct {
+	url         string
+	secret      []byte
+	maxAttempts int
+	client      *http.Client
+	queue       chan delivery
+	// mu guards closed so Notify never sends on the queue after Close has
+	// closed it, e.g. from handlers still running past the shutdown timeout.
+	mu     sync.RWMutex
+	closed bool
+	// limit, when set, paces posts (including retries) across workers.
+	limit *time.Ticker
+	// stop aborts backoff waits once Close runs out of time.
+	stop    context.Context
+	abandon context.CancelFunc
+	workers sync.WaitGroup
+}
+
+// NewNotifier starts the delivery workers. It returns nil when no URL is
+// configured; a nil Notifier silently ignores events.
+func NewNotifier(cfg config.WebhookConfig) *Notifier {
+	if cfg.URL == "" {
+		return nil
+	}
+
+	n := &Notifier{
+		url:         cfg.URL,
+		secret:      []byte(cfg.Secret),
+		maxAttempts: max(cfg.MaxAttempts, 1),
+		client:      &http.Client{Timeout: cfg.Timeout},
+		queue:       make(chan delivery, cfg.QueueSize),
+	}
+	if cfg.RateLimit > 0 {
+		n.limit = time.NewTicker(time.Duration(float64(time.Second) / cfg.RateLimit))
+	}
+	n.stop, n.abandon = context.WithCancel(context.Background())
+
+	for i := 0; i < max(cfg.Workers, 1); i++ {
+		n.workers.Add(1)
+		go n.run()
+	}
+	return n
+}
+
+func (n *Notifier) Notify(event string, payload any) {
+	if n == nil {
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Webhook %s: failed to encode payload: %v", event, err)
+		return
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		metrics.WebhookDeliveries.WithLabelValues("dropped").Inc()
+		log.Printf("Webhook %s: notifier closed, dropping event", event)
+		return
+	}
+	select {
+	case n.queue <- delivery{event: event, body: body}:
+		metrics.WebhookQueueDepth.Set(float64(len(n.queue)))
+	default:
+		metrics.WebhookDeliveries.WithLabelValues("dropped").Inc()
+		log.Printf("Webhook %s: queue full, dropping event", event)
+	}
+}
+
+// Close stops accepting events and waits for queued ones to be delivered
+// until ctx is done. Deliveries still pending then are dead-lettered
+// without further attempts. Events notified after Close are dropped.
+func (n *Notifier) Close(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	close(n.queue)
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.workers.Wait()
+		close(done)
+	}()
+
+	defer func() {
+		if n.limit != nil {
+			n.limit.Stop()
+		}
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		n.abandon()
+		<-done
+		return fmt.Errorf("webhook queue not drained in time: %w", ctx.Err())
+	}
+}
+
+func (n *Notifier) run() {
+	defer n.workers.Done()
+	for d := range n.queue {
+		metrics.WebhookQueueDepth.Set(float64(len(n.queue)))
+		n.deliver(d)
+	}
+}
+
+func (n *Notifier) deliver(d delivery) {
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		var err error
+		if err = n.wait(0); err == nil {
+			err = n.post(d)
+		}
+		if err == nil {
+			metrics.WebhookDeliveries.WithLabelValues("success").Inc()
+			return
+		}
+		if attempt >= n.maxAttempts || n.stop.Err() != nil {
+			n.deadLetter(d, attempt, err)
+			return
+		}
+		if n.wait(backoff) != nil {
+			n.deadLetter(d, attempt, err)
+			return
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// wait sleeps for delay and then for the rate limiter, returning early with
+// an error once Close gives up.
+func (n *Notifier) wait(delay time.Duration) error {
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-n.stop.Done():
+			return n.stop.Err()
+		}
+	}
+	if n.limit == nil {
+		return nil
+	}
+	select {
+	case <-n.limit.C:
+		return nil
+	case <-n.stop.Done():
+		return n.stop.Err()
+	}
+}
+
+// deadLetter records a delivery that permanently failed, with its body, so
+// it can be replayed by hand.
+func (n *Notifier) deadLetter(d delivery, attempts int, err error) {
+	metrics.WebhookDeliveries.WithLabelValues("failure").Inc()
+	slog.Error("Webhook dead letter", "event", d.event, "attempts", attempts, "error", err, "body", string(d.body))
+}
+
+func (n *Notifier) post(d delivery) error {
+	req, err := http.NewRequestWithContext(n.stop, http.MethodPost, n.url, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", d.event)
+	req.Header.Set(SignatureHeader, "sha256="+n.sign(d.body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (n *Notifier) sign(body []byte) string {
+	mac := hmac.New(sha256.New, n.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}